@@ -0,0 +1,517 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// Generate writes Go source for a function
+//
+//	func funcName(s string) int
+//
+// which returns the jump table index m.hashString(s) if s is a key of m, and
+// -1 otherwise. The tables are emitted as package level variables prefixed
+// with funcName.
+//
+// If all keys have distinct lengths (strlen is 0), the function switches on
+// len(s) and compares s to the only key of that length, without hashing.
+func (m *mphf) Generate(w io.Writer, funcName string) error {
+	return m.GenerateWith(w, funcName, GenerateOptions{})
+}
+
+// GenerateOptions selects variants of the code written by GenerateWith.
+type GenerateOptions struct {
+	// InlineCompare compares keys of up to maxInlineKey bytes byte by byte
+	// instead of with ==, and switches on the jump table index as
+	// GenerateHashSwitch does, as there is no key table to compare to.
+	InlineCompare bool
+
+	// IndexType is the return type of the lookup function written by
+	// GenerateSwitchWith: "uint8", "uint16" or "uint32", or "smallest" for
+	// the smallest of them that fits. The largest value of the type means not
+	// found, so the jump table must be smaller than that. The default is int,
+	// with -1 for not found.
+	IndexType string
+}
+
+// maxInlineKey is the longest key that InlineCompare compares byte by byte
+const maxInlineKey = 8
+
+// GenerateWith is Generate with opts.
+func (m *mphf) GenerateWith(w io.Writer, funcName string, opts GenerateOptions) error {
+	if m.fnv.strlen != 0 {
+		src := `{{template "func" .}}` + "\n" + `{{template "tables" .}}`
+		if opts.InlineCompare {
+			src = `{{template "hashswitch" .}}`
+		}
+		d := m.templateData(funcName, nil)
+		d.InlineCompare = opts.InlineCompare
+		return generateTemplate(w, template.Must(template.New(funcName).Parse(src)), d)
+	}
+
+	var buf bytes.Buffer
+	m.generateLengthSwitch(&buf, funcName, opts.InlineCompare)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// GenerateFromKeys writes a Go source file of package pkg with the lookup
+// function funcName for keys, as written by Generate. Duplicate keys are
+// removed, and the search uses SequentialSeeds, so the same keys always give
+// the same output. Returns an error if no MPHF is found.
+func GenerateFromKeys(w io.Writer, pkg, funcName string, keys []string) error {
+	m, ok := findMPHFOptions(append([]string(nil), keys...), Options{SequentialSeeds: true})
+	if !ok {
+		return errNotFound
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by go generate; DO NOT EDIT.\n\npackage %s\n\n", pkg)
+	if err := m.Generate(&buf, funcName); err != nil {
+		return err
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// errNotFound is returned when no MPHF is found for the keys
+var errNotFound = errors.New("mphf: could not find MPHF")
+
+// compareKey returns a Go expression which is true if s equals key. If
+// inline is set and key is short, the bytes of s are compared one by one.
+func compareKey(key string, inline bool) string {
+	if !inline || len(key) > maxInlineKey {
+		return fmt.Sprintf("s == %q", key)
+	}
+	expr := fmt.Sprintf("len(s) == %d", len(key))
+	for i := 0; i < len(key); i++ {
+		expr += fmt.Sprintf(" && s[%d] == %s", i, strconv.QuoteRuneToASCII(rune(key[i])))
+	}
+	return expr
+}
+
+// generateLengthSwitch writes a lookup function that switches on the key
+// length.
+func (m *mphf) generateLengthSwitch(buf *bytes.Buffer, funcName string, inline bool) {
+	entries := m.SortedEntries()
+	sort.SliceStable(entries, func(i, j int) bool {
+		return len(entries[i].Key) < len(entries[j].Key)
+	})
+
+	fmt.Fprintf(buf, "// %s returns the jump table index of s, or -1 if s is not a key.\n", funcName)
+	fmt.Fprintf(buf, "func %s(s string) int {\n", funcName)
+	fmt.Fprintf(buf, "\tswitch len(s) {\n")
+	for _, e := range entries {
+		fmt.Fprintf(buf, "\tcase %d:\n", len(e.Key))
+		fmt.Fprintf(buf, "\t\tif %s {\n", compareKey(e.Key, inline))
+		fmt.Fprintf(buf, "\t\t\treturn %d\n", e.Index)
+		fmt.Fprintf(buf, "\t\t}\n")
+	}
+	fmt.Fprintf(buf, "\t}\n")
+	fmt.Fprintf(buf, "\treturn -1\n")
+	fmt.Fprintf(buf, "}\n")
+}
+
+// GenerateLengthSwitch writes Go source for a lookup function like Generate,
+// which switches on len(s) and hashes, for each key length, only the bytes
+// that the hash of m uses for that length, unrolled. With PerLengthStrlen,
+// these are the bytes that tell apart the keys of the same length, see
+// LengthClassStrlen. If the hash of a length uses no content bytes, its jump
+// table index is a constant and the function only compares s to the key.
+func (m *mphf) GenerateLengthSwitch(w io.Writer, funcName string) error {
+	entries := m.SortedEntries()
+	byLen := make(map[int][]Entry)
+	for _, e := range entries {
+		byLen[len(e.Key)] = append(byLen[len(e.Key)], e)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// %s returns the jump table index of s, or -1 if s is not a key.\n", funcName)
+	fmt.Fprintf(&buf, "func %s(s string) int {\n", funcName)
+	fmt.Fprintf(&buf, "\tswitch len(s) {\n")
+	for _, n := range m.KeyLengths() {
+		fmt.Fprintf(&buf, "\tcase %d:\n", n)
+		indices := m.fnv.hashedIndices(n)
+		if len(indices) == 0 {
+			for _, e := range byLen[n] {
+				fmt.Fprintf(&buf, "\t\tif s == %q {\n", e.Key)
+				fmt.Fprintf(&buf, "\t\t\treturn %d\n", e.Index)
+				fmt.Fprintf(&buf, "\t\t}\n")
+			}
+			continue
+		}
+
+		// The sum of the offset and the length byte is a constant
+		sum := m.fnv.offset
+		if !m.fnv.noLength {
+			sum = m.fnv.hashByte(sum, byte(n))
+		}
+		fmt.Fprintf(&buf, "\t\tsum := uint32(0x%08x)\n", sum)
+		for _, i := range indices {
+			fmt.Fprintf(&buf, "\t\tsum ^= uint32(s[%d])\n", i)
+			fmt.Fprintf(&buf, "\t\tsum *= %d\n", prime32)
+		}
+		if m.noXor {
+			fmt.Fprintf(&buf, "\t\tix := (sum >> %sShifts[sum&%#x]) & %#x\n", funcName, m.bktMask, m.jmpMask)
+		} else {
+			fmt.Fprintf(&buf, "\t\tix := ((sum >> %sShifts[sum&%#x]) ^ sum) & %#x\n", funcName, m.bktMask, m.jmpMask)
+		}
+		fmt.Fprintf(&buf, "\t\tif %sKeys[ix] == s {\n", funcName)
+		fmt.Fprintf(&buf, "\t\t\treturn int(ix)\n")
+		fmt.Fprintf(&buf, "\t\t}\n")
+	}
+	fmt.Fprintf(&buf, "\t}\n")
+	fmt.Fprintf(&buf, "\treturn -1\n")
+	fmt.Fprintf(&buf, "}\n\n")
+
+	tmpl := template.Must(template.New(funcName).Parse(`{{template "tables" .}}`))
+	if err := generateTemplate(&buf, tmpl, m.templateData(funcName, nil)); err != nil {
+		return err
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// GenerateHashSwitch writes Go source for a lookup function like Generate, but
+// instead of a jump table of keys the function switches on the jump table
+// index, with one case per key. This leaves it to the compiler to build a jump
+// table.
+func (m *mphf) GenerateHashSwitch(w io.Writer, funcName string) error {
+	return m.GenerateSwitch(w, funcName, "")
+}
+
+// GenerateSwitch is GenerateHashSwitch, but if hashFunc is not empty the
+// lookup function calls hashFunc instead of hashing inline. hashFunc must be
+// generated by GenerateHashFunc with the hash function of m, so that several
+// lookup functions with the same hash function can share it.
+func (m *mphf) GenerateSwitch(w io.Writer, funcName, hashFunc string) error {
+	return m.GenerateSwitchWith(w, funcName, hashFunc, GenerateOptions{})
+}
+
+// GenerateSwitchWith is GenerateSwitch with opts. Returns an error if the jump
+// table indices and the not found value do not fit opts.IndexType.
+func (m *mphf) GenerateSwitchWith(w io.Writer, funcName, hashFunc string, opts GenerateOptions) error {
+	tmpl := template.Must(template.New(funcName).Parse(`{{template "hashswitch" .}}`))
+	d := m.templateData(funcName, nil)
+	d.HashFunc = hashFunc
+	d.InlineCompare = opts.InlineCompare
+	if opts.IndexType != "" {
+		var err error
+		if d.IndexType, d.NotFound, err = indexType(opts.IndexType, len(m.jmpTab)); err != nil {
+			return err
+		}
+	}
+	return generateTemplate(w, tmpl, d)
+}
+
+// indexType returns the Go type and the not found value of the unsigned
+// IndexType name for a jump table of size n
+func indexType(name string, n int) (typ, notFound string, err error) {
+	for _, bits := range []int{8, 16, 32} {
+		typ := fmt.Sprintf("uint%d", bits)
+		if name != typ && name != "smallest" {
+			continue
+		}
+		max := uint64(1)<<bits - 1
+		if uint64(n) > max {
+			if name == "smallest" {
+				continue
+			}
+			return "", "", fmt.Errorf("codegen: %d jump table slots do not fit %s", n, typ)
+		}
+		return typ, strconv.FormatUint(max, 10), nil
+	}
+	return "", "", fmt.Errorf("codegen: invalid index type %q", name)
+}
+
+// GenerateHashFunc writes Go source for a function
+//
+//	func funcName(s string) uint32
+//
+// which returns the hash sum f.hashString(s).
+func GenerateHashFunc(w io.Writer, funcName string, f fnv1a) error {
+	tmpl := template.Must(template.New(funcName).Parse(`{{template "hashfunc" .}}`))
+	return generateTemplate(w, tmpl, f.templateData(funcName))
+}
+
+// TemplateData is the data of the templates executed by GenerateWithTemplate.
+type TemplateData struct {
+	// Name is the name of the executed template, used as the function name
+	// and the prefix of the tables
+	Name string
+
+	// Offset and Prime are the FNV-1a offset basis, with the seed hashed
+	// in, and prime
+	Offset, Prime uint32
+
+	// Strlen is the number of bytes to hash. If Lens is set, it holds the
+	// number of bytes per length byte instead. If HasPositions is set, only
+	// the bytes at Positions are hashed.
+	Strlen       int
+	Lens         []TemplateLen
+	HasPositions bool
+	Positions    []int
+
+	// Suffix hashes the last Strlen bytes of s instead of the first, and
+	// NoLength leaves out the length byte
+	Suffix, NoLength bool
+
+	BktMask, JmpMask uint32
+	Shifts           []byte
+
+	// NoXor leaves out the xor with sum from the jump table index
+	NoXor bool
+
+	// Keys is the jump table. Empty slots hold the key of another slot.
+	Keys []string
+
+	// Entries holds the occupied jump table slots, ordered by index
+	Entries []Entry
+
+	// InlineCompare compares short keys byte by byte in the hashswitch
+	// section, see GenerateOptions
+	InlineCompare bool
+
+	// HashFunc, if set, is the name of a function generated by
+	// GenerateHashFunc, which the hash section calls instead of hashing inline
+	HashFunc string
+
+	// IndexType and NotFound are the return type of the hashswitch section
+	// and its value for strings that are not keys, see GenerateOptions
+	IndexType, NotFound string
+
+	// Data is the data passed to GenerateWithTemplate
+	Data any
+}
+
+// TemplateLen is the number of bytes to hash for a length byte
+type TemplateLen struct {
+	LenByte, Strlen int
+}
+
+// GenerateWithTemplate executes tmpl with the TemplateData of m, and writes
+// the output to w. data is available to the template as .Data.
+//
+// Templates can use the following sections, unless tmpl defines them itself:
+//
+//	hash       statements computing the hash sum of s into sum
+//	jmpix      an expression of the jump table index of sum
+//	index      a statement reducing sum to the jump table index
+//	body       the body of a lookup function returning the index or -1
+//	func       a lookup function named .Name
+//	tables     the shift and key tables
+//	hashswitch the lookup function and shift table of GenerateHashSwitch
+//	hashfunc   the hash function of GenerateHashFunc
+func (m *mphf) GenerateWithTemplate(w io.Writer, tmpl *template.Template, data any) error {
+	return generateTemplate(w, tmpl, m.templateData(tmpl.Name(), data))
+}
+
+// generateTemplate executes tmpl, with the sections of codegenSections that it
+// does not define, on d
+func generateTemplate(w io.Writer, tmpl *template.Template, d TemplateData) error {
+	t, err := tmpl.Clone()
+	if err != nil {
+		return err
+	}
+	t.Funcs(codegenFuncs)
+	for _, sect := range codegenSections.Templates() {
+		if t.Lookup(sect.Name()) == nil {
+			if _, err := t.AddParseTree(sect.Name(), sect.Tree); err != nil {
+				return err
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, d); err != nil {
+		return err
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// templateData returns the TemplateData of m
+func (m *mphf) templateData(name string, data any) TemplateData {
+	d := m.fnv.templateData(name)
+	d.BktMask = m.bktMask
+	d.JmpMask = m.jmpMask
+	d.Shifts = m.BucketShifts()
+	d.NoXor = m.noXor
+	d.IndexType, d.NotFound = "int", "-1"
+	d.Data = data
+
+	// An empty slot holds the key of another slot. That key never hashes to
+	// the empty slot, so the comparison in the lookup fails.
+	filler := ""
+	for _, e := range m.jmpTab {
+		if e.valid {
+			filler = e.key
+			break
+		}
+	}
+	for _, e := range m.jmpTab {
+		if e.valid {
+			d.Keys = append(d.Keys, e.key)
+		} else {
+			d.Keys = append(d.Keys, filler)
+		}
+	}
+	d.Entries = m.SortedEntries()
+	return d
+}
+
+// templateData returns the TemplateData of the hash function f
+func (f fnv1a) templateData(name string) TemplateData {
+	d := TemplateData{
+		Name:     name,
+		Offset:   f.offset,
+		Prime:    prime32,
+		Strlen:   f.strlen,
+		Suffix:   f.suffix,
+		NoLength: f.noLength,
+	}
+	if f.positions != nil {
+		d.HasPositions = true
+		d.Positions = *f.positions
+	} else if f.lens != nil {
+		for lb, strlen := range f.lens {
+			if strlen > 0 {
+				d.Lens = append(d.Lens, TemplateLen{lb, strlen})
+			}
+		}
+	}
+	return d
+}
+
+var codegenFuncs = template.FuncMap{
+	"compare": compareKey,
+	"hex": func(x uint32) string {
+		return fmt.Sprintf("%#x", x)
+	},
+	"join": func(xs any) string {
+		v := reflect.ValueOf(xs)
+		parts := make([]string, v.Len())
+		for i := range parts {
+			parts[i] = fmt.Sprint(v.Index(i))
+		}
+		return strings.Join(parts, ", ")
+	},
+}
+
+// codegenSections holds the sections available to GenerateWithTemplate
+var codegenSections = template.Must(template.New("").Funcs(codegenFuncs).Parse(`
+{{- define "hash"}}{{if .HashFunc}}	sum := {{.HashFunc}}(s)
+{{else}}	sum := uint32({{printf "0x%08x" .Offset}})
+{{- if not .NoLength}}
+	sum ^= uint32(byte(len(s)))
+	sum *= {{.Prime}}
+{{- end}}
+{{- if .HasPositions}}
+	for _, i := range [...]int{ {{- join .Positions}}} {
+		if i >= len(s) {
+			break
+		}
+{{- else if .Lens}}
+	n := 0
+	switch byte(len(s)) {
+{{- range .Lens}}
+	case {{.LenByte}}:
+		n = {{.Strlen}}
+{{- end}}
+	}
+	for i := 0; i < len(s) && i < n; i++ {
+{{- else if .Suffix}}
+	i := len(s) - {{.Strlen}}
+	if i < 0 {
+		i = 0
+	}
+	for ; i < len(s); i++ {
+{{- else}}
+	for i := 0; i < len(s) && i < {{.Strlen}}; i++ {
+{{- end}}
+		sum ^= uint32(s[i])
+		sum *= {{.Prime}}
+	}
+{{end}}{{end}}
+
+{{- define "jmpix" -}}
+{{if .NoXor}}(sum >> {{.Name}}Shifts[sum&{{hex .BktMask}}]) & {{hex .JmpMask}}
+{{- else}}((sum >> {{.Name}}Shifts[sum&{{hex .BktMask}}]) ^ sum) & {{hex .JmpMask}}
+{{- end}}
+{{- end}}
+
+{{- define "index"}}	sum = {{template "jmpix" .}}
+{{end}}
+
+{{- define "body"}}{{template "hash" .}}{{template "index" .}}	if {{.Name}}Keys[sum] != s {
+		return -1
+	}
+	return int(sum)
+{{end}}
+
+{{- define "func"}}// {{.Name}} returns the jump table index of s, or -1 if s is not a key.
+func {{.Name}}(s string) int {
+{{template "body" .}}}
+{{end}}
+
+{{- define "shifts"}}var {{.Name}}Shifts = [...]byte{ {{- join .Shifts}}}
+{{end}}
+
+{{- define "keys"}}// Empty slots hold the key of another slot, so they never match
+var {{.Name}}Keys = [...]string{
+{{- range .Keys}}
+	{{printf "%q" .}},
+{{- end}}
+}
+{{end}}
+
+{{- define "tables"}}{{template "shifts" .}}
+{{template "keys" .}}{{end}}
+
+{{- define "hashswitch"}}// {{.Name}} returns the jump table index of s, or {{.NotFound}} if s is not a key.
+func {{.Name}}(s string) {{.IndexType}} {
+{{template "hash" .}}	switch {{template "jmpix" .}} {
+{{- range .Entries}}
+	case {{.Index}}:
+		if {{compare .Key $.InlineCompare}} {
+			return {{.Index}}
+		}
+{{- end}}
+	}
+	return {{.NotFound}}
+}
+
+{{template "shifts" .}}{{end}}
+
+{{- define "hashfunc"}}// {{.Name}} returns the hash sum of s.
+func {{.Name}}(s string) uint32 {
+{{template "hash" .}}	return sum
+}
+{{end}}
+`))
+
+// GenerateFile finds a MPHF for keys and writes a Go source file of package
+// pkg with its lookup function funcName, as written by Generate. The seeds are
+// tried in sequence, so the output depends only on the keys.
+// Returns the MPHF of the lookup function.
+func GenerateFile(w io.Writer, pkg, funcName string, keys []string) (*mphf, error) {
+	m, ok := findMPHFOptions(append([]string(nil), keys...), Options{SequentialSeeds: true})
+	if !ok {
+		return nil, errNotFound
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by go generate; DO NOT EDIT.\n\npackage %s\n\n", pkg)
+	if err := m.Generate(&buf, funcName); err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
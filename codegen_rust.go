@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// GenerateRust writes Rust source for a function
+//
+//	pub fn funcName(s: &[u8]) -> i32
+//
+// which returns the jump table index m.hashString(s) if s is a key of m, and
+// -1 otherwise, like Generate. The tables are emitted as constants prefixed
+// with funcName in upper case. Callers pass a &str as s.as_bytes().
+func (m *mphf) GenerateRust(w io.Writer, funcName string) error {
+	prefix := strings.ToUpper(funcName)
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "/// %s returns the jump table index of s, or -1 if s is not a key.\n", funcName)
+	fmt.Fprintf(&buf, "pub fn %s(s: &[u8]) -> i32 {\n", funcName)
+	m.writeRustHash(&buf)
+	if m.noXor {
+		fmt.Fprintf(&buf, "    let ix = ((sum >> %s_SHIFTS[(sum & %#x) as usize]) & %#x) as usize;\n", prefix, m.bktMask, m.jmpMask)
+	} else {
+		fmt.Fprintf(&buf, "    let ix = (((sum >> %s_SHIFTS[(sum & %#x) as usize]) ^ sum) & %#x) as usize;\n", prefix, m.bktMask, m.jmpMask)
+	}
+	fmt.Fprintf(&buf, "    if %s_KEYS[ix] != s {\n", prefix)
+	fmt.Fprintf(&buf, "        return -1;\n")
+	fmt.Fprintf(&buf, "    }\n")
+	fmt.Fprintf(&buf, "    ix as i32\n")
+	fmt.Fprintf(&buf, "}\n\n")
+
+	shifts := m.BucketShifts()
+	fmt.Fprintf(&buf, "const %s_SHIFTS: [u8; %d] = [", prefix, len(shifts))
+	for i, shift := range shifts {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		fmt.Fprintf(&buf, "%d", shift)
+	}
+	fmt.Fprintf(&buf, "];\n\n")
+
+	// Empty slots hold the key of another slot, as in Generate
+	filler := ""
+	for _, e := range m.jmpTab {
+		if e.valid {
+			filler = e.key
+			break
+		}
+	}
+	fmt.Fprintf(&buf, "// Empty slots hold the key of another slot, so they never match\n")
+	fmt.Fprintf(&buf, "const %s_KEYS: [&[u8]; %d] = [\n", prefix, len(m.jmpTab))
+	for _, e := range m.jmpTab {
+		key := e.key
+		if !e.valid {
+			key = filler
+		}
+		fmt.Fprintf(&buf, "    %s,\n", rustBytes(key))
+	}
+	fmt.Fprintf(&buf, "];\n")
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// writeRustHash writes Rust statements that compute the fnv hash sum of s
+// into the variable sum. Rust panics on overflow in debug builds, so the
+// multiplications wrap explicitly.
+func (m *mphf) writeRustHash(buf *bytes.Buffer) {
+	fmt.Fprintf(buf, "    let mut sum: u32 = 0x%08x;\n", m.fnv.offset)
+	if !m.fnv.noLength {
+		fmt.Fprintf(buf, "    sum ^= s.len() as u8 as u32;\n")
+		fmt.Fprintf(buf, "    sum = sum.wrapping_mul(%d);\n", prime32)
+	}
+	if m.fnv.positions != nil {
+		// Hash only the variable byte offsets
+		fmt.Fprintf(buf, "    for &i in [")
+		for i, p := range *m.fnv.positions {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			fmt.Fprintf(buf, "%d", p)
+		}
+		fmt.Fprintf(buf, "].iter() {\n")
+		fmt.Fprintf(buf, "        if i >= s.len() {\n")
+		fmt.Fprintf(buf, "            break;\n")
+		fmt.Fprintf(buf, "        }\n")
+		fmt.Fprintf(buf, "        let c: u8 = s[i];\n")
+	} else {
+		if m.fnv.lens != nil {
+			// Look up the number of bytes to hash by the length byte
+			fmt.Fprintf(buf, "    let n: usize = match s.len() as u8 {\n")
+			for lb, strlen := range m.fnv.lens {
+				if strlen > 0 {
+					fmt.Fprintf(buf, "        %d => %d,\n", lb, strlen)
+				}
+			}
+			fmt.Fprintf(buf, "        _ => 0,\n")
+			fmt.Fprintf(buf, "    };\n")
+		} else {
+			fmt.Fprintf(buf, "    let n: usize = %d;\n", m.fnv.strlen)
+		}
+		if m.fnv.suffix {
+			fmt.Fprintf(buf, "    for &c in s.iter().skip(s.len().saturating_sub(n)) {\n")
+		} else {
+			fmt.Fprintf(buf, "    for &c in s.iter().take(n) {\n")
+		}
+	}
+	fmt.Fprintf(buf, "        sum ^= c as u32;\n")
+	fmt.Fprintf(buf, "        sum = sum.wrapping_mul(%d);\n", prime32)
+	fmt.Fprintf(buf, "    }\n")
+}
+
+// rustBytes returns s as a Rust byte string literal. Keys need not be valid
+// UTF-8, so bytes outside printable ASCII are escaped.
+func rustBytes(s string) string {
+	var b strings.Builder
+	b.WriteString(`b"`)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c >= ' ' && c <= '~':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, `\x%02x`, c)
+		}
+	}
+	b.WriteString(`"`)
+	return b.String()
+}
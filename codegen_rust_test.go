@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestGenerateRust(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping compilation of generated code in short mode")
+	}
+	rustc, err := exec.LookPath("rustc")
+	if err != nil {
+		t.Skip("rustc not found")
+	}
+
+	tests := []struct {
+		name  string
+		cases []string
+		opts  Options
+	}{
+		{"default", []string{"386", "amd64", "arm", "arm64", "ppc64", "wasm", "a\"b\\c", "\xff\x00"}, Options{}},
+		{"lengths", []string{"a", "bb", "ccc"}, Options{}},
+		{"perlength", []string{"a", "bb", "TrimPrefix", "TrimSuffix", "Trim", "Tree"}, Options{PerLengthStrlen: true}},
+		{"positions", []string{"img-a-b.png", "img-a-c.png", "img-b-b.png", "img-c-a.png", "img"}, Options{SkipConstantBytes: true}},
+		{"suffix", []string{"xa", "xb", "xc", "xd"}, Options{TryWindows: true, FixedStrLen: 1}},
+		{"suffixlength", []string{"xa", "xxa", "xb"}, Options{TryWindows: true, FixedStrLen: 1}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			m, ok := findMPHFOptions(append([]string(nil), tc.cases...), tc.opts)
+			if !ok {
+				t.Fatal("could not find MPHF")
+			}
+			var src bytes.Buffer
+			if err := m.GenerateRust(&src, "lookup"); err != nil {
+				t.Fatal(err)
+			}
+
+			queries := append([]string{"unknown", "", "x", "img-a-a.png"}, tc.cases...)
+			var prog bytes.Buffer
+			prog.Write(src.Bytes())
+			fmt.Fprintf(&prog, "\nfn main() {\n")
+			fmt.Fprintf(&prog, "    let queries: [&[u8]; %d] = [", len(queries))
+			for _, q := range queries {
+				fmt.Fprintf(&prog, "%s, ", rustBytes(q))
+			}
+			fmt.Fprintf(&prog, "];\n")
+			fmt.Fprintf(&prog, "    for q in queries.iter() {\n")
+			fmt.Fprintf(&prog, "        println!(\"{}\", lookup(q));\n")
+			fmt.Fprintf(&prog, "    }\n}\n")
+
+			dir, err := ioutil.TempDir("", "findhash")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			file := filepath.Join(dir, "main.rs")
+			if err := ioutil.WriteFile(file, prog.Bytes(), 0644); err != nil {
+				t.Fatal(err)
+			}
+			bin := filepath.Join(dir, "main")
+			if out, err := exec.Command(rustc, "-o", bin, file).CombinedOutput(); err != nil {
+				t.Fatalf("%v: %s\n%s", err, out, prog.Bytes())
+			}
+			out, err := exec.Command(bin).Output()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			results := strings.Fields(string(out))
+			if len(results) != len(queries) {
+				t.Fatalf("got %d results, expected %d", len(results), len(queries))
+			}
+			for i, q := range queries {
+				expected := -1
+				if ix := m.hashString(q); m.jmpTab[ix].valid && m.jmpTab[ix].key == q {
+					expected = int(ix)
+				}
+				if got, err := strconv.Atoi(results[i]); err != nil || got != expected {
+					t.Errorf("lookup(%q) = %s, expected %d", q, results[i], expected)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,399 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// checkGolden compares got to the golden file testdata/name.golden
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := ioutil.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, expected) {
+		t.Errorf("generated code differs from %s:\n%s", path, got)
+	}
+}
+
+// runGenerated compiles and runs a program with the generated src, and returns
+// the results of funcName for each query.
+func runGenerated(t *testing.T, src []byte, funcName string, queries []string) []int {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("skipping compilation of generated code in short mode")
+	}
+	goCmd, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+
+	dir, err := ioutil.TempDir("", "findhash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var prog bytes.Buffer
+	fmt.Fprintf(&prog, "package main\n\nimport \"fmt\"\n\n%s\n", src)
+	fmt.Fprintf(&prog, "func main() {\n")
+	fmt.Fprintf(&prog, "\tfor _, s := range %#v {\n", queries)
+	fmt.Fprintf(&prog, "\t\tfmt.Println(%s(s))\n", funcName)
+	fmt.Fprintf(&prog, "\t}\n}\n")
+
+	file := filepath.Join(dir, "main.go")
+	if err := ioutil.WriteFile(file, prog.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := exec.Command(goCmd, "run", file).CombinedOutput()
+	if err != nil {
+		t.Fatalf("%v: %s\n%s", err, out, prog.Bytes())
+	}
+
+	var results []int
+	for _, line := range strings.Fields(string(out)) {
+		n, err := strconv.Atoi(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		results = append(results, n)
+	}
+	return results
+}
+
+// checkGenerated runs the generated lookup for all cases and some unknown
+// strings, and compares the results to m.
+func checkGenerated(t *testing.T, m *mphf, src []byte, funcName string, cases []string) {
+	t.Helper()
+	queries := append([]string{"unknown", "", "x"}, cases...)
+	results := runGenerated(t, src, funcName, queries)
+	if len(results) != len(queries) {
+		t.Fatalf("got %d results, expected %d", len(results), len(queries))
+	}
+	for i, q := range queries {
+		expected := -1
+		if ix := m.hashString(q); m.jmpTab[ix].valid && m.jmpTab[ix].key == q {
+			expected = int(ix)
+		}
+		if results[i] != expected {
+			t.Errorf("%s(%q) = %d, expected %d", funcName, q, results[i], expected)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	tests := []struct {
+		name  string
+		cases []string
+	}{
+		{"lengths", []string{"a", "bb", "ccc", "dddddd"}},
+		{"jumptable", []string{"386", "amd64", "arm", "arm64", "ppc64", "wasm"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cases := append([]string(nil), tc.cases...)
+			m, ok := findMPHFOptions(cases, Options{Rand: rand.New(rand.NewSource(1))})
+			if !ok {
+				t.Fatal("could not find MPHF")
+			}
+
+			var buf bytes.Buffer
+			if err := m.Generate(&buf, "lookup"); err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, "generate_"+tc.name, buf.Bytes())
+			checkGenerated(t, m, buf.Bytes(), "lookup", tc.cases)
+		})
+	}
+}
+
+func TestGenerateLengthSwitch(t *testing.T) {
+	m, ok := findMPHF([]string{"a", "bb", "ccc"})
+	if !ok {
+		t.Fatal("could not find MPHF")
+	}
+	var buf bytes.Buffer
+	if err := m.Generate(&buf, "lookup"); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("switch len(s)")) {
+		t.Errorf("expected a length switch for distinct lengths:\n%s", buf.Bytes())
+	}
+	if bytes.Contains(buf.Bytes(), []byte("sum")) {
+		t.Errorf("expected no hashing for distinct lengths:\n%s", buf.Bytes())
+	}
+}
+
+func TestGeneratePerLengthStrlen(t *testing.T) {
+	cases := []string{"a", "bb", "ccc", "TrimPrefix", "TrimSuffix", "TrimString", "Trim", "Tree"}
+	m, ok := findMPHFOptions(append([]string(nil), cases...), Options{PerLengthStrlen: true})
+	if !ok {
+		t.Fatal("could not find MPHF")
+	}
+	var buf bytes.Buffer
+	if err := m.Generate(&buf, "lookup"); err != nil {
+		t.Fatal(err)
+	}
+	checkGenerated(t, m, buf.Bytes(), "lookup", cases)
+}
+
+func TestGenerateLengthSwitchStrlen(t *testing.T) {
+	cases := []string{"a", "bb", "ccc", "TrimPrefix", "TrimSuffix", "TrimString", "Trim", "Tree"}
+	m, ok := findMPHFOptions(append([]string(nil), cases...), Options{PerLengthStrlen: true, Rand: rand.New(rand.NewSource(1))})
+	if !ok {
+		t.Fatal("could not find MPHF")
+	}
+	expected := map[int]int{1: 0, 2: 0, 3: 0, 4: 3, 10: 6}
+	if strlens := m.LengthClassStrlen(); !reflect.DeepEqual(strlens, expected) {
+		t.Errorf("LengthClassStrlen() = %v, expected %v", strlens, expected)
+	}
+
+	var buf bytes.Buffer
+	if err := m.GenerateLengthSwitch(&buf, "lookup"); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "s[6]") {
+		t.Errorf("expected at most 6 hashed bytes:\n%s", buf.Bytes())
+	}
+	checkGolden(t, "generate_lengthswitch", buf.Bytes())
+	checkGenerated(t, m, buf.Bytes(), "lookup", cases)
+}
+
+func TestGenerateHashSwitch(t *testing.T) {
+	cases := []string{"386", "amd64", "arm", "arm64", "ppc64", "wasm"}
+	m, ok := findMPHFOptions(append([]string(nil), cases...), Options{Rand: rand.New(rand.NewSource(1))})
+	if !ok {
+		t.Fatal("could not find MPHF")
+	}
+
+	var buf bytes.Buffer
+	if err := m.GenerateHashSwitch(&buf, "lookup"); err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "generate_hashswitch", buf.Bytes())
+	checkGenerated(t, m, buf.Bytes(), "lookup", cases)
+}
+
+func TestGenerateIndexType(t *testing.T) {
+	cases := []string{"386", "amd64", "arm", "arm64", "ppc64", "wasm"}
+	m, ok := findMPHFOptions(append([]string(nil), cases...), Options{Rand: rand.New(rand.NewSource(1))})
+	if !ok {
+		t.Fatal("could not find MPHF")
+	}
+
+	var buf bytes.Buffer
+	if err := m.GenerateSwitchWith(&buf, "lookup", "", GenerateOptions{IndexType: "smallest"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "func lookup(s string) uint8 {") {
+		t.Errorf("expected a uint8 index:\n%s", buf.Bytes())
+	}
+	checkGolden(t, "generate_uint8", buf.Bytes())
+
+	queries := append([]string{"unknown", ""}, cases...)
+	results := runGenerated(t, buf.Bytes(), "lookup", queries)
+	for i, q := range queries {
+		expected := 255
+		if ix, ok := m.Lookup(q); ok {
+			expected = ix
+		}
+		if i >= len(results) || results[i] != expected {
+			t.Errorf("lookup(%q) = %v, expected %d", q, results, expected)
+		}
+	}
+
+	for _, tc := range []struct {
+		name, typ string
+		n         int
+	}{
+		{"smallest", "uint8", 255},
+		{"smallest", "uint16", 256},
+		{"uint32", "uint32", 4},
+	} {
+		if typ, _, err := indexType(tc.name, tc.n); err != nil || typ != tc.typ {
+			t.Errorf("indexType(%q, %d) = %q, %v; expected %q", tc.name, tc.n, typ, err, tc.typ)
+		}
+	}
+	if _, _, err := indexType("uint8", 256); err == nil {
+		t.Error("expected an error for 256 slots in uint8")
+	}
+	if _, _, err := indexType("int8", 4); err == nil {
+		t.Error("expected an error for int8")
+	}
+}
+
+func TestGenerateHashFunc(t *testing.T) {
+	cases := []string{"386", "amd64", "arm", "arm64", "ppc64", "wasm"}
+	m, ok := findMPHFOptions(append([]string(nil), cases...), Options{Rand: rand.New(rand.NewSource(1))})
+	if !ok {
+		t.Fatal("could not find MPHF")
+	}
+
+	var buf bytes.Buffer
+	if err := GenerateHashFunc(&buf, "hashKey", m.fnv); err != nil {
+		t.Fatal(err)
+	}
+	buf.WriteString("\n")
+	if err := m.GenerateSwitch(&buf, "lookup", "hashKey"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "\tsum := hashKey(s)\n") {
+		t.Errorf("lookup does not call hashKey:\n%s", buf.Bytes())
+	}
+	checkGolden(t, "generate_hashfunc", buf.Bytes())
+	checkGenerated(t, m, buf.Bytes(), "lookup", cases)
+}
+
+func TestGenerateInlineCompare(t *testing.T) {
+	cases := []string{"386", "amd64", "arm", "arm64", "ppc64", "wasm", "riscv64le-long"}
+	m, ok := findMPHFOptions(append([]string(nil), cases...), Options{Rand: rand.New(rand.NewSource(1))})
+	if !ok {
+		t.Fatal("could not find MPHF")
+	}
+
+	var buf bytes.Buffer
+	if err := m.GenerateWith(&buf, "lookup", GenerateOptions{InlineCompare: true}); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"len(s) == 3 && s[0] == 'a' && s[1] == 'r' && s[2] == 'm'", `s == "riscv64le-long"`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected %q in generated code:\n%s", want, buf.Bytes())
+		}
+	}
+	checkGolden(t, "generate_inline", buf.Bytes())
+	checkGenerated(t, m, buf.Bytes(), "lookup", cases)
+
+	lengths := []string{"", "a", "bb", "c\x00\xff"}
+	m, ok = findMPHF(append([]string(nil), lengths...))
+	if !ok {
+		t.Fatal("could not find MPHF")
+	}
+	buf.Reset()
+	if err := m.GenerateWith(&buf, "lookup", GenerateOptions{InlineCompare: true}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "s ==") {
+		t.Errorf("expected inline comparisons:\n%s", buf.Bytes())
+	}
+	checkGenerated(t, m, buf.Bytes(), "lookup", lengths)
+}
+
+func TestGenerateSkipConstantBytes(t *testing.T) {
+	cases := []string{"img-a-b.png", "img-a-c.png", "img-b-b.png", "img-b-c.png", "img-c-a.png", "img"}
+	m, ok := findMPHFOptions(append([]string(nil), cases...), Options{SkipConstantBytes: true})
+	if !ok {
+		t.Fatal("could not find MPHF")
+	}
+	var buf bytes.Buffer
+	if err := m.Generate(&buf, "lookup"); err != nil {
+		t.Fatal(err)
+	}
+	checkGenerated(t, m, buf.Bytes(), "lookup", cases)
+}
+
+func TestGenerateWithTemplate(t *testing.T) {
+	cases := []string{"386", "amd64", "arm", "arm64", "ppc64", "wasm"}
+	m, ok := findMPHF(append([]string(nil), cases...))
+	if !ok {
+		t.Fatal("could not find MPHF")
+	}
+
+	tmpl := template.Must(template.New("lookup").Parse(`// {{.Name}} is {{.Data}}
+func {{.Name}}(s string) (int, bool) {
+{{template "hash" .}}{{template "index" .}}	return int(sum), {{.Name}}Keys[sum] == s
+}
+
+{{template "tables" .}}
+func check(s string) int {
+	if ix, ok := {{.Name}}(s); ok {
+		return ix
+	}
+	return -1
+}
+`))
+	var buf bytes.Buffer
+	if err := m.GenerateWithTemplate(&buf, tmpl, "generated"); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"// lookup is generated\n", "func lookup(s string) (int, bool) {\n"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected %q in generated code:\n%s", want, buf.Bytes())
+		}
+	}
+	checkGenerated(t, m, buf.Bytes(), "check", cases)
+}
+
+func TestGenerateFromKeys(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping compilation of generated code in short mode")
+	}
+	goCmd, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+
+	keys := []string{"386", "amd64", "arm", "arm64", "ppc64", "wasm", "arm"}
+	var buf bytes.Buffer
+	if err := GenerateFromKeys(&buf, "main", "lookup", keys); err != nil {
+		t.Fatal(err)
+	}
+	var again bytes.Buffer
+	if err := GenerateFromKeys(&again, "main", "lookup", keys); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), again.Bytes()) {
+		t.Errorf("output differs between runs:\n%s\n%s", buf.Bytes(), again.Bytes())
+	}
+
+	dir := t.TempDir()
+	queries := append([]string{"unknown", ""}, keys...)
+	prog := fmt.Sprintf("package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfor _, s := range %#v {\n\t\tfmt.Println(lookup(s))\n\t}\n}\n", queries)
+	for name, src := range map[string][]byte{"lookup.go": buf.Bytes(), "main.go": []byte(prog)} {
+		if err := os.WriteFile(filepath.Join(dir, name), src, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cmd := exec.Command(goCmd, "run", "lookup.go", "main.go")
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("%v: %s\n%s", err, out, buf.Bytes())
+	}
+
+	results := strings.Fields(string(out))
+	if len(results) != len(queries) || results[0] != "-1" || results[1] != "-1" {
+		t.Fatalf("got %v for %q, expected -1 for the unknown keys", results, queries)
+	}
+	seen := make(map[string]string)
+	for i, key := range keys {
+		ix := results[i+2]
+		if ix == "-1" {
+			t.Errorf("lookup(%q) = -1", key)
+		}
+		if other, ok := seen[ix]; ok && other != key {
+			t.Errorf("lookup(%q) = lookup(%q) = %s", key, other, ix)
+		}
+		seen[ix] = key
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// CollationOpts selects transforms that make keys equal under a collation.
+// They are applied in the order of the fields, before Options.Canonicalize.
+//
+// The composition and decomposition of accented letters covers Latin-1 only,
+// as the package has no Unicode normalization tables. Other characters are
+// left as they are.
+type CollationOpts struct {
+	// ComposeLatin1 composes a Latin-1 letter followed by a combining accent
+	// into the precomposed letter, so that "e\u0301" becomes "é". It is the
+	// Latin-1 subset of Unicode NFC; other sequences are not composed.
+	ComposeLatin1 bool
+
+	// FoldCase maps letters to lower case
+	FoldCase bool
+
+	// StripAccents replaces accented Latin-1 letters with their base letter,
+	// and removes combining marks
+	StripAccents bool
+}
+
+// latin1Accents lists the precomposed Latin-1 letters by combining accent
+var latin1Accents = []struct {
+	mark           rune
+	base, composed string
+}{
+	{'\u0300', "AEIOUaeiou", "ÀÈÌÒÙàèìòù"},
+	{'\u0301', "AEIOUYaeiouy", "ÁÉÍÓÚÝáéíóúý"},
+	{'\u0302', "AEIOUaeiou", "ÂÊÎÔÛâêîôû"},
+	{'\u0303', "ANOano", "ÃÑÕãñõ"},
+	{'\u0308', "AEIOUaeiouy", "ÄËÏÖÜäëïöüÿ"},
+	{'\u030a', "Aa", "Åå"},
+	{'\u0327', "Cc", "Çç"},
+}
+
+// composeTab maps a letter and a combining accent to the precomposed letter,
+// and baseTab maps a precomposed letter to its base letter
+var composeTab, baseTab = latin1Tables()
+
+func latin1Tables() (map[[2]rune]rune, map[rune]rune) {
+	compose := make(map[[2]rune]rune)
+	base := make(map[rune]rune)
+	for _, a := range latin1Accents {
+		composed := []rune(a.composed)
+		for i, b := range []rune(a.base) {
+			compose[[2]rune{b, a.mark}] = composed[i]
+			base[composed[i]] = b
+		}
+	}
+	return compose, base
+}
+
+// enabled returns true if c transforms keys
+func (c CollationOpts) enabled() bool {
+	return c.ComposeLatin1 || c.FoldCase || c.StripAccents
+}
+
+// apply returns the collation key of s
+func (c CollationOpts) apply(s string) string {
+	if c.ComposeLatin1 {
+		var out []rune
+		for _, r := range s {
+			if n := len(out); n > 0 {
+				if composed, ok := composeTab[[2]rune{out[n-1], r}]; ok {
+					out[n-1] = composed
+					continue
+				}
+			}
+			out = append(out, r)
+		}
+		s = string(out)
+	}
+	if c.FoldCase {
+		s = strings.ToLower(s)
+	}
+	if c.StripAccents {
+		s = strings.Map(func(r rune) rune {
+			if b, ok := baseTab[r]; ok {
+				return b
+			}
+			if unicode.Is(unicode.Mn, r) {
+				return -1
+			}
+			return r
+		}, s)
+	}
+	return s
+}
+
+// canonicalize returns the function applying c and then canon. Returns canon
+// if c does not transform keys.
+func (c CollationOpts) canonicalize(canon func(string) string) func(string) string {
+	if !c.enabled() {
+		return canon
+	}
+	if canon == nil {
+		return c.apply
+	}
+	return func(s string) string {
+		return canon(c.apply(s))
+	}
+}
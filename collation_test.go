@@ -0,0 +1,43 @@
+package main
+
+import "testing"
+
+func TestCollation(t *testing.T) {
+	tests := []struct {
+		coll        CollationOpts
+		a, b        string
+		same        bool
+		description string
+	}{
+		{CollationOpts{FoldCase: true, StripAccents: true}, "Café", "cafe", true, "fold case and strip accents"},
+		{CollationOpts{FoldCase: true, StripAccents: true}, "CAFE\u0301", "café", true, "strip combining accent"},
+		{CollationOpts{FoldCase: true}, "Café", "cafe", false, "fold case only"},
+		{CollationOpts{StripAccents: true}, "Café", "cafe", false, "strip accents only"},
+		{CollationOpts{ComposeLatin1: true}, "cafe\u0301", "café", true, "compose accent"},
+		{CollationOpts{ComposeLatin1: true}, "cafe", "café", false, "keep accent"},
+		{CollationOpts{}, "cafe\u0301", "café", false, "no collation"},
+	}
+	for _, tc := range tests {
+		if same := tc.coll.apply(tc.a) == tc.coll.apply(tc.b); same != tc.same {
+			t.Errorf("%s: got %q and %q, same %v, expected %v", tc.description, tc.coll.apply(tc.a), tc.coll.apply(tc.b), same, tc.same)
+		}
+	}
+
+	opts := Options{Collation: CollationOpts{ComposeLatin1: true, FoldCase: true, StripAccents: true}}
+	m, ok := findMPHFOptions([]string{"Café", "cafe", "Thé", "tea"}, opts)
+	if !ok {
+		t.Fatal("could not find MPHF")
+	}
+	if n := len(m.keys()); n != 3 {
+		t.Errorf("got %d keys, expected 3", n)
+	}
+	cafe, _ := m.Lookup("cafe")
+	for _, key := range []string{"Café", "CAFÉ", "cafe\u0301"} {
+		if ix, ok := m.Lookup(key); !ok || ix != cafe {
+			t.Errorf("Lookup(%q) = %d, %v, expected %d", key, ix, ok, cafe)
+		}
+	}
+	if _, ok := m.Lookup("coffee"); ok {
+		t.Error("found an unknown key")
+	}
+}
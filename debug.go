@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// WriteDot writes the mphf as a Graphviz graph. There is a node for each bucket
+// and for each occupied jump table slot, and an edge from each key's bucket to
+// its slot. Bucket nodes are labeled with their shift value.
+func (m *mphf) WriteDot(w io.Writer) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "digraph mphf {\n")
+	fmt.Fprintf(&buf, "\trankdir=LR;\n")
+	for bkt, shift := range m.BucketShifts() {
+		fmt.Fprintf(&buf, "\tb%d [shape=box, label=\"bucket %d\\nshift %d\"];\n", bkt, bkt, shift)
+	}
+	for ix, e := range m.jmpTab {
+		if e.valid {
+			fmt.Fprintf(&buf, "\ts%d [label=%q];\n", ix, fmt.Sprintf("%d: %q", ix, e.key))
+		}
+	}
+	for ix, e := range m.jmpTab {
+		if e.valid {
+			bkt := m.fnv.hashString(e.key) & m.bktMask
+			fmt.Fprintf(&buf, "\tb%d -> s%d;\n", bkt, ix)
+		}
+	}
+	fmt.Fprintf(&buf, "}\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// Layout returns the keys grouped by bucket, in bucket order. The keys of a
+// bucket are ordered by their jump table index. Empty buckets are left out.
+func (m *mphf) Layout() [][]string {
+	buckets := make([][]string, m.bktMask+1)
+	for _, e := range m.jmpTab {
+		if e.valid {
+			bkt := m.fnv.hashString(e.key) & m.bktMask
+			buckets[bkt] = append(buckets[bkt], e.key)
+		}
+	}
+
+	layout := buckets[:0]
+	for _, keys := range buckets {
+		if len(keys) > 0 {
+			layout = append(layout, keys)
+		}
+	}
+	return layout
+}
+
+// ShiftFor returns the shift value of the bucket of key. The jump table index
+// of key is m.jmpIx(m.fnv.hashString(m.canonical(key)), m.ShiftFor(key)).
+func (m *mphf) ShiftFor(key string) byte {
+	return m.shiftAt(m.fnv.hashString(m.canonical(key)) & m.bktMask)
+}
+
+// IndexForMask returns the jump table index of key for a jump table of size
+// mask+1, with the current hash and shift values. This shows where the keys
+// would land if the jump table were resized.
+func (m *mphf) IndexForMask(key string, mask uint32) uint32 {
+	sum := m.fnv.hashString(m.canonical(key))
+	resized := *m
+	resized.jmpMask = mask
+	return resized.jmpIx(sum, m.shiftAt(sum&m.bktMask))
+}
+
+// CollisionsAtSize returns the groups of keys that would share a jump table
+// slot if the jump table had n slots, with the current hash and shift values.
+// n is rounded up to a power of 2, like the jump table size. The groups are in
+// slot order, and the keys of a group in jump table order. Returns nil if the
+// keys fit n slots without collisions.
+func (m *mphf) CollisionsAtSize(n int) [][]string {
+	size := 1
+	for size < n {
+		size <<= 1
+	}
+	slots := make(map[uint32][]string)
+	for _, e := range m.jmpTab {
+		if e.valid {
+			ix := m.IndexForMask(e.key, uint32(size-1))
+			slots[ix] = append(slots[ix], e.key)
+		}
+	}
+
+	var collisions [][]string
+	for ix := 0; ix < size; ix++ {
+		if keys := slots[uint32(ix)]; len(keys) > 1 {
+			collisions = append(collisions, keys)
+		}
+	}
+	return collisions
+}
+
+// BucketMates returns the keys in the bucket of key, in jump table order. The
+// result includes key itself if it is in the set.
+func (m *mphf) BucketMates(key string) []string {
+	bkt := m.fnv.hashString(m.canonical(key)) & m.bktMask
+	var mates []string
+	for _, e := range m.jmpTab {
+		if e.valid && m.fnv.hashString(e.key)&m.bktMask == bkt {
+			mates = append(mates, e.key)
+		}
+	}
+	return mates
+}
+
+// EntryAt returns the key in jump table slot index, and whether the slot is
+// occupied. Returns false if index is outside the jump table.
+func (m *mphf) EntryAt(index int) (key string, valid bool) {
+	if index < 0 || index >= len(m.jmpTab) {
+		return "", false
+	}
+	e := m.jmpTab[index]
+	return e.key, e.valid
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestWriteDot(t *testing.T) {
+	cases := []string{"386", "amd64", "arm", "arm64", "ppc64", "wasm", "mips", "s390x"}
+	m, ok := findMPHF(cases)
+	if !ok {
+		t.Fatal("could not find MPHF")
+	}
+
+	var buf bytes.Buffer
+	if err := m.WriteDot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	dot := buf.String()
+
+	if !strings.HasPrefix(dot, "digraph mphf {\n") || !strings.HasSuffix(dot, "}\n") {
+		t.Errorf("not a digraph:\n%s", dot)
+	}
+	counts := []struct {
+		re       string
+		expected int
+	}{
+		{`(?m)^\tb\d+ \[shape=box`, len(m.bktShift)},
+		{`(?m)^\ts\d+ \[label=`, len(cases)},
+		{`(?m)^\tb\d+ -> s\d+;$`, len(cases)},
+	}
+	for _, c := range counts {
+		if got := len(regexp.MustCompile(c.re).FindAllString(dot, -1)); got != c.expected {
+			t.Errorf("got %d matches for %s, expected %d:\n%s", got, c.re, c.expected, dot)
+		}
+	}
+}
+
+func TestLayout(t *testing.T) {
+	for _, cases := range testcases {
+		m, ok := findMPHF(cases)
+		if !ok {
+			t.Fatal("could not find MPHF")
+		}
+
+		seen := make(map[string]int)
+		for _, keys := range m.Layout() {
+			if len(keys) == 0 {
+				t.Errorf("got empty bucket in layout for %v", cases)
+			}
+			bkt := m.fnv.hashString(keys[0]) & m.bktMask
+			prev := -1
+			for _, key := range keys {
+				if b := m.fnv.hashString(key) & m.bktMask; b != bkt {
+					t.Errorf("got %q from bucket %d in bucket %d", key, b, bkt)
+				}
+				ix := int(m.hashString(key))
+				if ix <= prev {
+					t.Errorf("got %q at index %d after index %d", key, ix, prev)
+				}
+				prev = ix
+				seen[key]++
+			}
+		}
+
+		for _, e := range m.jmpTab {
+			if e.valid && seen[e.key] != 1 {
+				t.Errorf("got %q %d times in layout, expected once", e.key, seen[e.key])
+			}
+		}
+		if len(seen) != len(Deduplicate(cases)) {
+			t.Errorf("got %d keys in layout, expected %d", len(seen), len(cases))
+		}
+	}
+}
+
+func TestShiftFor(t *testing.T) {
+	for _, cases := range testcases {
+		m, ok := findMPHF(cases)
+		if !ok {
+			t.Fatal("could not find MPHF")
+		}
+		for _, key := range cases {
+			sum := m.fnv.hashString(key)
+			if ix := m.jmpIx(sum, m.ShiftFor(key)); ix != m.hashString(key) {
+				t.Errorf("got index %d with ShiftFor(%q), expected %d", ix, key, m.hashString(key))
+			}
+		}
+	}
+}
+
+func TestIndexForMask(t *testing.T) {
+	for _, cases := range testcases {
+		m, ok := findMPHF(cases)
+		if !ok {
+			t.Fatal("could not find MPHF")
+		}
+		doubled := m.jmpMask<<1 | 1
+		for _, key := range cases {
+			ix := m.hashString(key)
+			if got := m.IndexForMask(key, m.jmpMask); got != ix {
+				t.Errorf("IndexForMask(%q, current mask) = %d, expected %d", key, got, ix)
+			}
+			got := m.IndexForMask(key, doubled)
+			if got > doubled || got&m.jmpMask != ix {
+				t.Errorf("IndexForMask(%q, doubled mask) = %d, expected %d or %d", key, got, ix, ix+m.jmpMask+1)
+			}
+		}
+	}
+}
+
+func TestCollisionsAtSize(t *testing.T) {
+	var cases []string
+	for i := 0; i < 20; i++ {
+		cases = append(cases, fmt.Sprintf("key%02d", i))
+	}
+	m, ok := findMPHFOptions(cases, Options{GrowthFactor: 2})
+	if !ok {
+		t.Fatal("could not find MPHF")
+	}
+	size := len(m.jmpTab)
+	if got := m.CollisionsAtSize(size); got != nil {
+		t.Errorf("got collisions %q at the current size", got)
+	}
+
+	// 20 keys cannot fit 16 slots
+	collisions := m.CollisionsAtSize(16)
+	if len(collisions) == 0 {
+		t.Fatal("expected collisions at size 16")
+	}
+	colliding := 0
+	for _, group := range collisions {
+		if len(group) < 2 {
+			t.Errorf("got group %q of less than 2 keys", group)
+		}
+		for _, key := range group[1:] {
+			if m.IndexForMask(key, 15) != m.IndexForMask(group[0], 15) {
+				t.Errorf("%q and %q do not collide", key, group[0])
+			}
+		}
+		colliding += len(group)
+	}
+	if colliding-len(collisions) < len(cases)-16 {
+		t.Errorf("got %d displaced keys, expected at least %d", colliding-len(collisions), len(cases)-16)
+	}
+}
+
+func TestSortedEntries(t *testing.T) {
+	cases := []string{"386", "amd64", "arm", "arm64", "ppc64", "wasm", "mips", "s390x"}
+	reversed := make([]string, len(cases))
+	for i, key := range cases {
+		reversed[len(cases)-1-i] = key
+	}
+
+	m1, ok := findMPHFOptions(cases, Options{Rand: rand.New(rand.NewSource(1))})
+	if !ok {
+		t.Fatal("could not find MPHF")
+	}
+	m2, ok := findMPHFOptions(reversed, Options{Rand: rand.New(rand.NewSource(1))})
+	if !ok {
+		t.Fatal("could not find MPHF")
+	}
+
+	entries := m1.SortedEntries()
+	if !reflect.DeepEqual(entries, m2.SortedEntries()) {
+		t.Errorf("got different entries for the same seed:\n%v\n%v", entries, m2.SortedEntries())
+	}
+	if len(entries) != len(cases) {
+		t.Errorf("got %d entries, expected %d", len(entries), len(cases))
+	}
+	for i, e := range entries {
+		if ix, ok := m1.Lookup(e.Key); !ok || ix != e.Index {
+			t.Errorf("got index %d for %q, expected %d", e.Index, e.Key, ix)
+		}
+		if i > 0 && e.Index <= entries[i-1].Index {
+			t.Errorf("got index %d after %d", e.Index, entries[i-1].Index)
+		}
+	}
+}
+
+func TestBucketMates(t *testing.T) {
+	for _, cases := range testcases {
+		m, ok := findMPHF(cases)
+		if !ok {
+			t.Fatal("could not find MPHF")
+		}
+		for _, key := range cases {
+			bkt := m.fnv.hashString(key) & m.bktMask
+			var expected []string
+			for _, e := range m.jmpTab {
+				if e.valid && m.fnv.hashString(e.key)&m.bktMask == bkt {
+					expected = append(expected, e.key)
+				}
+			}
+
+			mates := m.BucketMates(key)
+			found := false
+			for _, mate := range mates {
+				found = found || mate == key
+			}
+			if !found || !reflect.DeepEqual(mates, expected) {
+				t.Errorf("got bucket mates %q for %q, expected %q", mates, key, expected)
+			}
+		}
+	}
+}
+
+func TestAll(t *testing.T) {
+	for _, cases := range testcases {
+		m, ok := findMPHF(cases)
+		if !ok {
+			t.Fatal("could not find MPHF")
+		}
+		var entries []Entry
+		for key, ix := range m.All() {
+			entries = append(entries, Entry{key, ix})
+		}
+		if !reflect.DeepEqual(entries, m.SortedEntries()) {
+			t.Errorf("got %v from All, expected %v", entries, m.SortedEntries())
+		}
+
+		// Stop early
+		n := 0
+		for range m.All() {
+			n++
+			break
+		}
+		if n != 1 {
+			t.Errorf("got %d iterations after break", n)
+		}
+	}
+}
+
+func TestEntryAt(t *testing.T) {
+	for _, cases := range testcases {
+		m, ok := findMPHF(cases)
+		if !ok {
+			t.Fatal("could not find MPHF")
+		}
+		n := 0
+		for ix := -1; ix <= len(m.jmpTab); ix++ {
+			key, valid := m.EntryAt(ix)
+			if !valid {
+				if key != "" {
+					t.Errorf("EntryAt(%d) = %q, false; expected an empty key", ix, key)
+				}
+				continue
+			}
+			n++
+			if got, ok := m.Lookup(key); !ok || got != ix {
+				t.Errorf("EntryAt(%d) = %q, but Lookup(%q) = %d, %v", ix, key, key, got, ok)
+			}
+		}
+		if n != len(m.SortedEntries()) {
+			t.Errorf("got %d valid entries, expected %d", n, len(m.SortedEntries()))
+		}
+	}
+}
+
+func TestIndices(t *testing.T) {
+	for _, cases := range testcases {
+		m, ok := findMPHF(cases)
+		if !ok {
+			t.Fatal("could not find MPHF")
+		}
+		indices := m.Indices()
+		if len(indices) != len(m.SortedEntries()) {
+			t.Errorf("got %d indices, expected %d", len(indices), len(m.SortedEntries()))
+		}
+		seen := make(map[int]string)
+		for key, ix := range indices {
+			if other, ok := seen[ix]; ok {
+				t.Errorf("%q and %q have index %d", key, other, ix)
+			}
+			seen[ix] = key
+			if got, ok := m.Lookup(key); !ok || got != ix {
+				t.Errorf("Indices()[%q] = %d, but Lookup gives %d, %v", key, ix, got, ok)
+			}
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+// Enum maps the names of an enumeration to their values, the index of the
+// name in the list given to NewEnum, and back.
+type Enum struct {
+	values *Map[int]
+	names  []string // indexed by value
+}
+
+// NewEnum finds a MPHF for names. Returns false if a name occurs more than
+// once, or if no MPHF is found.
+func NewEnum(names []string) (*Enum, bool) {
+	values := make([]int, len(names))
+	seen := make(map[string]bool)
+	for i, name := range names {
+		if seen[name] {
+			return nil, false
+		}
+		seen[name] = true
+		values[i] = i
+	}
+	mp, ok := findMap(names, values)
+	if !ok {
+		return nil, false
+	}
+	return &Enum{values: mp, names: append([]string(nil), names...)}, true
+}
+
+// Value returns the value of name, or false if name is not in the enum.
+func (e *Enum) Value(name string) (int, bool) {
+	return e.values.Get(name)
+}
+
+// Name returns the name of value, or false if value is out of range.
+func (e *Enum) Name(value int) (string, bool) {
+	if value < 0 || value >= len(e.names) {
+		return "", false
+	}
+	return e.names[value], true
+}
@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+func TestEnum(t *testing.T) {
+	names := []string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"}
+	e, ok := NewEnum(names)
+	if !ok {
+		t.Fatal("could not build enum")
+	}
+	for i, name := range names {
+		value, ok := e.Value(name)
+		if !ok || value != i {
+			t.Errorf("Value(%q) = %d, %v, expected %d", name, value, ok, i)
+		}
+		if got, ok := e.Name(value); !ok || got != name {
+			t.Errorf("Name(%d) = %q, %v, expected %q", value, got, ok, name)
+		}
+	}
+
+	if value, ok := e.Value("Someday"); ok {
+		t.Errorf("Value of unknown name = %d", value)
+	}
+	for _, value := range []int{-1, len(names)} {
+		if name, ok := e.Name(value); ok {
+			t.Errorf("Name(%d) = %q, expected out of range", value, name)
+		}
+	}
+	if _, ok := NewEnum([]string{"on", "off", "on"}); ok {
+		t.Error("built enum with a repeated name")
+	}
+}
@@ -1,32 +1,98 @@
 package main
 
 import (
+	"bytes"
+	"flag"
 	"fmt"
+	"iter"
+	"math"
 	"math/rand"
+	"os"
+	"reflect"
+	"slices"
 	"sort"
+	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 const maxAttempts = 100 // maximum amount of seeds to try
 
 // findHash tries seeds until it finds a perfect hash function.
+// Returns the hash function and the seed it was created from, so that
+// newFnv1a(seed, fnv.strlen) reproduces it.
 // Returns true if found, false if no success after maxAttempts iterations.
-func findHash(cases []string) (fnv1a, bool) {
+func findHash(cases []string) (fnv1a, uint32, bool) {
+	return findHashOptions(cases, Options{})
+}
+
+// FindPerfectHash finds a hash function without collisions over cases. The
+// hash is perfect but not minimal: the sums are spread over all of uint32, so
+// callers index them themselves. cases is not modified.
+// Returns false if no hash function is found.
+func FindPerfectHash(cases []string) (fnv1a, bool) {
+	fnv, _, ok := findHash(append([]string(nil), cases...))
+	return fnv, ok
+}
+
+// Hash returns the hash sum of s
+func (f fnv1a) Hash(s string) uint32 {
+	return f.hashString(s)
+}
+
+// findHashOptions is findHash with seeds drawn from opts.
+func findHashOptions(cases []string, opts Options) (fnv1a, uint32, bool) {
+	opts = opts.preset()
+
 	// Prepare input data
-	cases = deduplicate(cases)
-	strlen := minInputLen(cases)
+	cases = Deduplicate(cases)
+	return findHashWith(cases, hashTemplate(cases, MinInputLen(cases), opts), opts)
+}
+
+// hashTemplate returns an unseeded fnv1a that hashes strlen bytes of the
+// deduplicated cases, or the bytes selected by PerLengthStrlen or
+// SkipConstantBytes in opts.
+func hashTemplate(cases []string, strlen int, opts Options) fnv1a {
+	f := fnv1a{strlen: strlen}
+	switch {
+	case opts.SkipConstantBytes:
+		positions := variablePositions(cases, strlen)
+		f.positions = &positions
+	case opts.PerLengthStrlen:
+		f.lens = minInputLens(cases, strlen-MinInputLen(cases))
+	}
+	return f
+}
 
-	for i := 0; i < maxAttempts; i++ {
-		fnv := newFnv1a(rand.Uint32(), strlen)
-		if !hasCollisions(cases, fnv) {
-			return fnv, true
+// findHashWith tries seeds for f until it finds a perfect hash function for
+// the deduplicated cases.
+func findHashWith(cases []string, f fnv1a, opts Options) (fnv1a, uint32, bool) {
+	fnv, seed, _, ok := findSumsWith(cases, f, opts)
+	return fnv, seed, ok
+}
+
+// findSumsWith is findHashWith, and also returns the hash sums of the cases.
+// The sums depend on the seed, but they can be passed on to newMPHFSums so
+// that the cases are not hashed again for the same seed. It tries at most
+// opts.attempts() seeds.
+func findSumsWith(cases []string, f fnv1a, opts Options) (fnv1a, uint32, []uint32, bool) {
+	sums := make([]uint32, len(cases))
+	for i := 0; i < opts.attempts(); i++ {
+		seed := opts.seed()
+		fnv := f.withSeed(seed)
+		if hashSums(cases, fnv, sums) {
+			return fnv, seed, sums, true
 		}
 	}
-	return fnv1a{}, false
+	return fnv1a{}, 0, nil, false
 }
 
-// deduplicate sorts and discards duplicates from data
-func deduplicate(data []string) []string {
+// Deduplicate sorts data in place and discards duplicates. The returned slice
+// shares the backing array of data.
+func Deduplicate(data []string) []string {
+	if len(data) == 0 {
+		return data
+	}
 	sort.Strings(data)
 	j := 0
 	for i := 1; i < len(data); i++ {
@@ -41,10 +107,13 @@ func deduplicate(data []string) []string {
 	return data[:j+1]
 }
 
-// minInputLen finds the minimal length that uniquely identifies a case string
+// MinInputLen finds the minimal length that uniquely identifies a case string
 // Return 0 if [string length modulo 256] is unique for each string. Otherwise return the
 // minimum number of bytes required to uniquely identify each case.
-func minInputLen(cases []string) int {
+// The result is not limited to 255: keys whose lengths alias modulo 256 and
+// that differ only beyond byte 255 get a strlen past the first difference.
+// The cases must be distinct. They are not modified.
+func MinInputLen(cases []string) int {
 	// Check if string lengths mod 256 are unique to each case
 	lengths := make(map[byte]struct{})
 	for _, str := range cases {
@@ -55,6 +124,7 @@ func minInputLen(cases []string) int {
 		return 0
 	}
 
+	cases = append([]string(nil), cases...)
 	sort.Strings(cases)
 	uniqueLen := 0
 	for i := 1; i < len(cases); i++ {
@@ -72,18 +142,111 @@ func minInputLen(cases []string) int {
 	return uniqueLen
 }
 
+// CheckLengthAliasing returns the pairs of cases whose lengths differ but
+// are the same modulo 256. hashString only hashes the low byte of the length,
+// so such keys are told apart by their bytes alone. The first key of a pair is
+// the shorter one.
+func CheckLengthAliasing(cases []string) (aliased [][2]string) {
+	sorted := append([]string(nil), cases...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if len(sorted[i]) != len(sorted[j]) {
+			return len(sorted[i]) < len(sorted[j])
+		}
+		return sorted[i] < sorted[j]
+	})
+	var groups [256][]string
+	for _, str := range sorted {
+		groups[byte(len(str))] = append(groups[byte(len(str))], str)
+	}
+	for _, group := range groups {
+		for i, a := range group {
+			for _, b := range group[i+1:] {
+				if len(a) != len(b) {
+					aliased = append(aliased, [2]string{a, b})
+				}
+			}
+		}
+	}
+	return aliased
+}
+
+// minInputLens finds the minimal length that uniquely identifies a case string
+// among the cases with the same length modulo 256. The result is indexed by the
+// length byte, and extra is added to the length of each group with more than
+// one case.
+func minInputLens(cases []string, extra int) *[256]int {
+	var groups [256][]string
+	for _, str := range cases {
+		groups[byte(len(str))] = append(groups[byte(len(str))], str)
+	}
+
+	var lens [256]int
+	for i, group := range groups {
+		if len(group) > 1 {
+			lens[i] = MinInputLen(group) + extra
+		}
+	}
+	return &lens
+}
+
+// variablePositions returns the byte offsets below strlen where the cases
+// differ. An offset is constant, and left out, only if all cases are longer
+// than the offset and have the same byte there.
+func variablePositions(cases []string, strlen int) []int {
+	positions := []int{}
+	for p := 0; p < strlen; p++ {
+		for _, str := range cases {
+			if len(str) <= p || str[p] != cases[0][p] {
+				positions = append(positions, p)
+				break
+			}
+		}
+	}
+	return positions
+}
+
 // hasCollisions returns true if fnv hashes collide for any two cases
 func hasCollisions(cases []string, fnv fnv1a) bool {
-	hashes := make(map[uint32]struct{})
+	return !hashSums(cases, fnv, make([]uint32, len(cases)))
+}
 
+// fallbackHash returns the hash function with seed 0 for the cases. Unlike
+// findHash it always returns a hash function, but its sums may collide, as
+// reported by collisionPair. It lets callers inspect cases for which no perfect
+// hash function is found.
+func fallbackHash(cases []string) fnv1a {
+	cases = Deduplicate(append([]string(nil), cases...))
+	return hashTemplate(cases, MinInputLen(cases), Options{}).withSeed(0)
+}
+
+// collisionPair returns two distinct cases with the same fnv hash sum.
+// Returns false if there are none.
+func collisionPair(cases []string, fnv fnv1a) (string, string, bool) {
+	seen := make(map[uint32]string, len(cases))
 	for _, str := range cases {
+		sum := fnv.hashString(str)
+		if prev, exists := seen[sum]; exists && prev != str {
+			return prev, str, true
+		}
+		seen[sum] = str
+	}
+	return "", "", false
+}
+
+// hashSums sets sums[i] to the hash sum of cases[i].
+// Returns false if two cases have the same sum.
+func hashSums(cases []string, fnv fnv1a, sums []uint32) bool {
+	hashes := make(map[uint32]struct{}, len(cases))
+
+	for i, str := range cases {
 		sum := fnv.hashString(str)
 		if _, exists := hashes[sum]; exists {
-			return true
+			return false
 		}
 		hashes[sum] = struct{}{}
+		sums[i] = sum
 	}
-	return false
+	return true
 }
 
 const (
@@ -94,13 +257,22 @@ const (
 
 // fnv1a is used to calculate the FNV-1a 32-bit hash
 type fnv1a struct {
-	offset uint32 // seeded initial sum
-	strlen int    // maximum bytes to hash
+	offset    uint32    // seeded initial sum
+	strlen    int       // maximum bytes to hash
+	lens      *[256]int // maximum bytes to hash by length byte, overrides strlen
+	positions *[]int    // sorted byte offsets to hash, overrides strlen
+	suffix    bool      // hash the last strlen bytes instead of the first
+	noLength  bool      // leave out the length byte
 }
 
 // newFnv1a returns a seeded fnv1a
 func newFnv1a(seed uint32, strlen int) fnv1a {
-	f := fnv1a{offset32, strlen}
+	return fnv1a{strlen: strlen}.withSeed(seed)
+}
+
+// withSeed returns f with the initial sum seeded by seed
+func (f fnv1a) withSeed(seed uint32) fnv1a {
+	f.offset = offset32
 	// Hash the seed into f.offset
 	for _, w := range []int{0, 8, 16, 24} {
 		f.offset = f.hashByte(f.offset, byte(seed>>w))
@@ -119,35 +291,522 @@ func (_ fnv1a) hashByte(sum uint32, data byte) uint32 {
 
 // hashString hashes first the length of the string, truncated to one byte, and
 // then up to strlen bytes, or to the end of the string. Whichever comes first.
+// If lens is set, strlen is looked up by the length byte. If positions is set,
+// only the bytes at those offsets are hashed.
 func (f fnv1a) hashString(input string) uint32 {
+	if f.lens != nil || f.positions != nil || f.suffix || f.noLength {
+		return f.hashSelected(input)
+	}
+
 	// Truncate string length to one byte and hash it
 	sum := f.hashByte(f.offset, byte(len(input)))
 
 	// Hash input[:f.strlen]
-	for i := 0; i < len(input) && i < f.strlen; i++ {
-		sum = f.hashByte(sum, input[i])
+	n := f.strlen
+	if n > len(input) {
+		n = len(input)
+	}
+	for _, c := range []byte(input[:n]) {
+		sum = f.hashByte(sum, c)
+	}
+	return sum
+}
+
+// hashSelected is hashString for the bytes selected by lens or positions.
+// It is kept apart so that hashString can be inlined.
+func (f fnv1a) hashSelected(input string) uint32 {
+	sum := f.offset
+	if !f.noLength {
+		sum = f.hashByte(sum, byte(len(input)))
+	}
+	if f.positions != nil {
+		for _, p := range *f.positions {
+			if p >= len(input) {
+				break
+			}
+			sum = f.hashByte(sum, input[p])
+		}
+		return sum
+	}
+	window := input[:f.inputLen(input)]
+	if f.suffix {
+		window = input[len(input)-len(window):]
+	}
+	for _, c := range []byte(window) {
+		sum = f.hashByte(sum, c)
 	}
 	return sum
 }
 
+// HashesLength reports whether hashString hashes the length byte,
+// byte(len(input)), before the content bytes. Code reproducing the hash in
+// another language must do the same. fnv1a hashes it unless findMPHF fell
+// back to a window without it.
+func (f fnv1a) HashesLength() bool {
+	return !f.noLength
+}
+
+// hashedIndices returns the offsets of the content bytes that hashString
+// hashes for inputs of length n, in hashing order
+func (f fnv1a) hashedIndices(n int) []int {
+	var indices []int
+	if f.positions != nil {
+		for _, p := range *f.positions {
+			if p >= n {
+				break
+			}
+			indices = append(indices, p)
+		}
+		return indices
+	}
+	hashed := f.inputLen(strings.Repeat("\x00", n))
+	start := 0
+	if f.suffix {
+		start = n - hashed
+	}
+	for i := start; i < start+hashed; i++ {
+		indices = append(indices, i)
+	}
+	return indices
+}
+
+// String returns the parameters of f, for logs and test failures
+func (f fnv1a) String() string {
+	s := fmt.Sprintf("fnv1a{offset: %#08x, strlen: %d, length byte: %v", f.offset, f.strlen, f.HashesLength())
+	if f.suffix {
+		s += ", suffix"
+	}
+	if f.lens != nil {
+		s += ", per-length strlen"
+	}
+	if f.positions != nil {
+		s += fmt.Sprintf(", positions: %v", *f.positions)
+	}
+	return s + "}"
+}
+
+// inputLen returns the number of bytes of input that hashString hashes
+func (f fnv1a) inputLen(input string) int {
+	n := len(input)
+	if f.positions != nil {
+		hashed := 0
+		for _, p := range *f.positions {
+			if p < n {
+				hashed++
+			}
+		}
+		return hashed
+	}
+	strlen := f.strlen
+	if f.lens != nil {
+		strlen = f.lens[byte(n)]
+	}
+	if n > strlen {
+		n = strlen
+	}
+	return n
+}
+
 // findMPHF tries seeds until it finds a near minimal perfect hash function.
 // Returns true if found, false if no success after maxAttempts iterations.
 func findMPHF(cases []string) (*mphf, bool) {
+	return findMPHFOptions(cases, Options{})
+}
+
+// Options configures the search for a MPHF. The zero value gives the default
+// behavior of findMPHF.
+type Options struct {
+	// Rand is the source of seeds. The global source is used if nil.
+	Rand *rand.Rand
+
+	// SequentialSeeds tries the seeds 1, 2, 3, ... instead of seeds from
+	// Rand, so that each search of the same cases has the same result.
+	SequentialSeeds bool
+	lastSeed        *uint32 // the last sequential seed, set by preset
+
+	progress      func(attempt int) // called before each attempt, see FindMPHFProgress
+	minStrlenOnly bool              // search only at the minimal strlen, see FindMPHFFast
+
+	// Attempts is the number of hash functions to try for each strlen, and
+	// the number of seeds each of them tries to hash the keys without
+	// collisions. Defaults to maxAttempts.
+	Attempts int
+
+	// Retry, if set, decides how many attempts to make and how many bytes to
+	// hash in each, instead of Attempts and FixedStrLen. By default, the
+	// search makes Attempts attempts with each strlen from MinInputLen up.
+	Retry RetryStrategy
+
+	// ShufflePerAttempt reshuffles the cases after each failed attempt. The
+	// order of the cases breaks ties between equal sized buckets in
+	// initBuckets, so a new order may avoid a dead end.
+	ShufflePerAttempt bool
+
+	// PerLengthStrlen hashes only as many bytes as needed to tell apart the
+	// keys with the same length byte, instead of the same number of bytes for
+	// all keys.
+	PerLengthStrlen bool
+
+	// SkipConstantBytes hashes only the byte offsets, within strlen, where the
+	// keys differ. It takes precedence over PerLengthStrlen.
+	SkipConstantBytes bool
+
+	// GrowthFactor reserves jump table capacity for later calls to Add. The
+	// jump table size is the smallest power of 2 greater than N*GrowthFactor.
+	// Values below 1 are ignored.
+	GrowthFactor float64
+
+	// FixedStrLen, if positive, hashes exactly that many bytes of each key
+	// instead of searching upward from MinInputLen. This keeps strlen, and
+	// the generated code, stable when keys are added. The search fails if
+	// FixedStrLen bytes cannot tell the keys apart.
+	FixedStrLen int
+
+	// TryNoXor retries a failed construction with the jump table index
+	// sum>>shift instead of (sum>>shift)^sum. The two give different
+	// collisions, so a seed that fails with one may succeed with the other.
+	TryNoXor bool
+
+	// TryWindows retries a failed search with other bytes of the keys: the
+	// prefix without the length byte, and the suffix without and with the
+	// length byte. Keys that differ only near the end need fewer bytes of the
+	// suffix. The MPHF records the window, and generated code hashes the same
+	// bytes. PerLengthStrlen and SkipConstantBytes apply to the prefix with
+	// the length byte only.
+	TryWindows bool
+
+	// UnsortedBuckets places the buckets in bucket order instead of largest
+	// first. Sorting costs O(B log B) per attempt for B buckets, but placing
+	// the large buckets while the jump table is empty makes each attempt more
+	// likely to succeed. Without sorting, sets with similar bucket sizes build
+	// faster per attempt, and skewed sets need more attempts or fail.
+	UnsortedBuckets bool
+
+	// MaxTableSize, if positive, is the largest jump table, in slots, that the
+	// search may allocate. The search fails for larger sets instead, which
+	// bounds the memory of the MPHF.
+	MaxTableSize int
+
+	// PackShifts stores the shift values in 5 bits each instead of a byte,
+	// which saves memory for large sets at the cost of slower lookups.
+	PackShifts bool
+
+	// ReservedSlots are jump table indices that no key may map to, such as
+	// an index reserved as invalid. Indices outside the table are ignored.
+	// The mphf keeps them, so Add and MarshalBinary honour them too.
+	ReservedSlots []int
+
+	// Canonicalize, if set, maps each key to its canonical form before
+	// deduplication, and each query before lookup. Keys with the same
+	// canonical form are the same key. Generated code and MarshalBinary do
+	// not include it, so callers must canonicalize their queries.
+	Canonicalize func(string) string
+
+	// Collation composes normalization, case folding and accent stripping,
+	// applied before Canonicalize at build and lookup.
+	Collation CollationOpts
+
+	// Exclude lists keys to leave out of the MPHF even if they are given,
+	// such as "" when it means no match. Keys are compared after
+	// Canonicalize.
+	Exclude []string
+
+	// RequireUTF8 rejects the cases if any of them is not valid UTF-8, which
+	// in a set of text keys usually means corrupt input. FindMPHFOptions
+	// returns an error that lists the invalid keys.
+	RequireUTF8 bool
+
+	// Optimize selects a preset for the options above.
+	Optimize OptimizeMode
+}
+
+// OptimizeMode is a preset of Options for speed or size.
+type OptimizeMode int
+
+const (
+	// OptimizeDefault uses the options as given.
+	OptimizeDefault OptimizeMode = iota
+
+	// OptimizeSpeed doubles the jump table size and hashes as few bytes as
+	// possible with PerLengthStrlen. The sparser table makes the search
+	// succeed in fewer attempts.
+	OptimizeSpeed
+
+	// OptimizeSize uses the minimal jump table size, ignoring GrowthFactor.
+	OptimizeSize
+)
+
+// preset returns o with the preset of o.Optimize applied, and the sequential
+// seeds of a new search
+func (o Options) preset() Options {
+	if o.SequentialSeeds {
+		o.lastSeed = new(uint32)
+	}
+	switch o.Optimize {
+	case OptimizeSpeed:
+		if o.GrowthFactor < 2 {
+			o.GrowthFactor = 2
+		}
+		o.PerLengthStrlen = true
+	case OptimizeSize:
+		o.GrowthFactor = 0
+	}
+	return o
+}
+
+// seed returns the next sequential seed, or a random seed from o.Rand or the
+// global source
+func (o Options) seed() uint32 {
+	if o.lastSeed != nil {
+		*o.lastSeed++
+		return *o.lastSeed
+	}
+	if o.Rand != nil {
+		return o.Rand.Uint32()
+	}
+	return rand.Uint32()
+}
+
+// attempts returns the number of attempts to make
+func (o Options) attempts() int {
+	if o.Attempts > 0 {
+		return o.Attempts
+	}
+	return maxAttempts
+}
+
+// findMPHFOptions is findMPHF configured by opts.
+func findMPHFOptions(cases []string, opts Options) (*mphf, bool) {
+	m, _, _, ok := findMPHFCount(cases, opts)
+	return m, ok
+}
+
+// FindMPHFProgress is findMPHF, but calls progress before each attempt, with
+// the number of the attempt from 1, so that a tool can report progress while
+// building a large MPHF.
+func FindMPHFProgress(cases []string, progress func(attempt int)) (*mphf, bool) {
+	return findMPHFOptions(cases, Options{progress: progress})
+}
+
+// FindMPHFOptions is findMPHF configured by opts. Returns an error if the
+// cases fail the checks of opts, such as RequireUTF8, and false if no MPHF
+// was found.
+func FindMPHFOptions(cases []string, opts Options) (*mphf, bool, error) {
+	if err := opts.check(cases); err != nil {
+		return nil, false, err
+	}
+	m, ok := findMPHFOptions(cases, opts)
+	return m, ok, nil
+}
+
+// check returns an error if the cases fail the checks of o
+func (o Options) check(cases []string) error {
+	if o.RequireUTF8 {
+		var invalid []string
+		for _, str := range cases {
+			if !utf8.ValidString(str) {
+				invalid = append(invalid, str)
+			}
+		}
+		if len(invalid) > 0 {
+			return fmt.Errorf("mphf: keys are not valid UTF-8: %q", invalid)
+		}
+	}
+	return nil
+}
+
+// findMPHFCount is findMPHFOptions, and also returns the buildInfo of the
+// mphf and the number of attempts made.
+func findMPHFCount(cases []string, opts Options) (m *mphf, info buildInfo, attempts int, ok bool) {
+	opts = opts.preset()
+	opts.Canonicalize = opts.Collation.canonicalize(opts.Canonicalize)
+
 	// Prepare input data
-	cases = deduplicate(cases)
-
-	for i := 0; i < maxAttempts; i++ {
-		fnv, ok := findHash(cases)
-		//fnv := newFnv1a(rand.Uint32(), strlen)
-		//if !hasCollisions(cases, fnv) {
-		if ok {
-			m, ok := newMPHF(cases, fnv)
-			if ok {
-				return m, true
+	if opts.Canonicalize != nil {
+		canon := make([]string, len(cases))
+		for i, str := range cases {
+			canon[i] = opts.Canonicalize(str)
+		}
+		cases = canon
+	}
+	if len(opts.Exclude) > 0 {
+		exclude := make(map[string]bool)
+		for _, str := range opts.Exclude {
+			if opts.Canonicalize != nil {
+				str = opts.Canonicalize(str)
+			}
+			exclude[str] = true
+		}
+		var kept []string
+		for _, str := range cases {
+			if !exclude[str] {
+				kept = append(kept, str)
 			}
 		}
+		cases = kept
 	}
-	return nil, false
+	return searchMPHF(Deduplicate(cases), opts)
+}
+
+// searchMPHF is findMPHFCount for sorted, distinct cases and opts with the
+// preset applied.
+func searchMPHF(cases []string, opts Options) (m *mphf, info buildInfo, attempts int, ok bool) {
+	order := cases
+	var perm []int
+	if opts.ShufflePerAttempt {
+		// findHash sorts cases, so shuffle a copy. perm maps the shuffled
+		// order back to cases, to reorder the hash sums.
+		order = append([]string(nil), cases...)
+		perm = make([]int, len(cases))
+		for i := range perm {
+			perm[i] = i
+		}
+	}
+
+	if opts.MaxTableSize > 0 && jmpSize(len(cases), opts) > opts.MaxTableSize {
+		return nil, info, 0, false
+	}
+
+	maxLen := 0
+	for _, str := range cases {
+		if len(str) > maxLen {
+			maxLen = len(str)
+		}
+	}
+
+	// try makes one attempt with tmpl and a new seed
+	try := func(tmpl fnv1a) (*mphf, buildInfo, error) {
+		attempts++
+		if opts.progress != nil {
+			opts.progress(attempts)
+		}
+		fnv, seed, sums, ok := findSumsWith(cases, tmpl, opts)
+		if !ok {
+			return nil, buildInfo{}, errSumCollision
+		}
+		if perm != nil {
+			ordered := make([]uint32, len(sums))
+			for i, j := range perm {
+				ordered[i] = sums[j]
+			}
+			sums = ordered
+		}
+		m, info, ok := newMPHFInfo(order, sums, fnv, opts)
+		if !ok {
+			return nil, buildInfo{}, errNoShifts
+		}
+		m.seed = seed
+		m.canon = opts.Canonicalize
+		return m, info, nil
+	}
+
+	// The first window is the prefix of the keys after the length byte. If no
+	// MPHF is found with it, TryWindows falls back to the other windows, those
+	// without the length byte first as they hash one byte less.
+	windows := searchWindows[:1]
+	if opts.TryWindows {
+		windows = searchWindows
+	}
+	for i, window := range windows {
+		first := MinInputLen(cases)
+		if i > 0 {
+			if first = minWindowLen(cases, window); first < 0 {
+				continue
+			}
+		}
+		retry := opts.Retry
+		if retry == nil {
+			last := maxLen
+			if opts.minStrlenOnly {
+				last = first
+			}
+			if opts.FixedStrLen > 0 {
+				if opts.FixedStrLen < first {
+					continue
+				}
+				first, last = opts.FixedStrLen, opts.FixedStrLen
+			}
+			retry = defaultRetry{first, last, opts.attempts()}
+		}
+
+		// The strategy may hash more bytes when the search stalls. The final
+		// strlen is recorded in the fnv of the mphf.
+		tmpl, tmplLen := fnv1a{}, -1
+		var err error
+		for attempt := 0; ; attempt++ {
+			tryAgain, strlen := retry.Next(attempt, err)
+			if !tryAgain {
+				break
+			}
+			if strlen != tmplLen {
+				tmpl, tmplLen = hashTemplate(cases, strlen, opts), strlen
+				if i > 0 {
+					tmpl = window
+					tmpl.strlen = strlen
+				}
+			}
+			var m *mphf
+			if m, info, err = try(tmpl); err == nil {
+				return m, info, attempts, true
+			}
+
+			if opts.ShufflePerAttempt {
+				shuffle := rand.Shuffle
+				if opts.Rand != nil {
+					shuffle = opts.Rand.Shuffle
+				}
+				shuffle(len(order), func(i, j int) {
+					order[i], order[j] = order[j], order[i]
+					perm[i], perm[j] = perm[j], perm[i]
+				})
+			}
+		}
+	}
+	return nil, info, attempts, false
+}
+
+// searchWindows are the bytes that searchMPHF hashes, in the order it tries
+// them. Only the first window uses the PerLengthStrlen and SkipConstantBytes
+// options.
+var searchWindows = []fnv1a{
+	{},
+	{noLength: true},
+	{noLength: true, suffix: true},
+	{suffix: true},
+}
+
+// minWindowLen returns the minimal strlen for which window tells the cases
+// apart, or -1 if none does.
+func minWindowLen(cases []string, window fnv1a) int {
+	maxLen := 0
+	for _, str := range cases {
+		if len(str) > maxLen {
+			maxLen = len(str)
+		}
+	}
+	distinct := func(strlen int) bool {
+		window.strlen = strlen
+		seen := make(map[string]bool, len(cases))
+		for _, str := range cases {
+			n := window.inputLen(str)
+			w := str[:n]
+			if window.suffix {
+				w = str[len(str)-n:]
+			}
+			if !window.noLength {
+				w = string(rune(byte(len(str)))) + w
+			}
+			if seen[w] {
+				return false
+			}
+			seen[w] = true
+		}
+		return true
+	}
+	if !distinct(maxLen) {
+		return -1
+	}
+	return sort.Search(maxLen, distinct)
 }
 
 // mphf is a (near) minimal perfect hash function used for a jump table.
@@ -175,85 +834,463 @@ func findMPHF(cases []string) (*mphf, bool) {
 
 type mphf struct {
 	fnv      fnv1a
+	seed     uint32 // seed of fnv
 	bktShift []byte
+	packed   []uint64 // bktShift packed by packShifts, replaces bktShift
+	reserved []int    // sorted jump table slots that no key may map to
 	bktMask  uint32
 	jmpTab   []jmpEntry
 	jmpMask  uint32
+	lengths  []int   // sorted distinct lengths of the keys
+	rank     []int32 // number of keys before each jump table slot
+	noXor    bool    // jmpIx leaves out the xor with sum
+
+	canon func(string) string // Options.Canonicalize
 }
 
 // jmpIx calculates the jump table index for a fnv hash sum
 func (m mphf) jmpIx(sum uint32, shift byte) uint32 {
+	if m.noXor {
+		return (sum >> shift) & m.jmpMask
+	}
 	return ((sum >> shift) ^ sum) & m.jmpMask
 }
 
 // hashString calculates the near minimal perfect hash sum for data
 func (m mphf) hashString(data string) uint32 {
 	sum := m.fnv.hashString(data)
-	return m.jmpIx(sum, m.bktShift[sum&m.bktMask])
+	return m.jmpIx(sum, m.shiftAt(sum&m.bktMask))
+}
+
+// Lookup returns the jump table index of key. Returns false if key is not in
+// the set.
+func (m *mphf) Lookup(key string) (int, bool) {
+	key = m.canonical(key)
+	ix := m.hashString(key)
+	if e := m.jmpTab[ix]; !e.valid || e.key != key {
+		return -1, false
+	}
+	return int(ix), true
+}
+
+// LookupAll sets out[i] to the jump table index of keys[i], or -1 if keys[i]
+// is not in the set. out must have room for len(keys) indices.
+func (m *mphf) LookupAll(keys []string, out []int) {
+	out = out[:len(keys)]
+	for i, key := range keys {
+		key = m.canonical(key)
+		ix := m.hashString(key)
+		if e := m.jmpTab[ix]; e.valid && e.key == key {
+			out[i] = int(ix)
+		} else {
+			out[i] = -1
+		}
+	}
+}
+
+// MayContain reports whether the jump table slot of key is occupied, without
+// comparing key to the stored key. There are no false negatives, but a string
+// which is not in the set may hash to an occupied slot and give a false
+// positive. Use Lookup unless only keys of the set are queried.
+func (m *mphf) MayContain(key string) bool {
+	return m.jmpTab[m.hashString(m.canonical(key))].valid
+}
+
+// PossibleMember reports whether a key of the set has the length of s. It
+// does not hash s, so it is a cheap filter before Lookup for queries that are
+// mostly not in the set.
+func (m *mphf) PossibleMember(s string) bool {
+	n := len(m.canonical(s))
+	i := sort.SearchInts(m.lengths, n)
+	return i < len(m.lengths) && m.lengths[i] == n
+}
+
+// LengthClassStrlen returns the number of content bytes that the hash uses
+// for keys of each key length. With PerLengthStrlen, this is the minimal
+// number of bytes that tell apart the keys of the same length byte.
+func (m *mphf) LengthClassStrlen() map[int]int {
+	strlens := make(map[int]int)
+	for _, n := range m.lengths {
+		strlens[n] = len(m.fnv.hashedIndices(n))
+	}
+	return strlens
+}
+
+// KeyLengths returns the sorted distinct lengths of the keys
+func (m *mphf) KeyLengths() []int {
+	return append([]int(nil), m.lengths...)
+}
+
+// canonical returns the canonical form of key
+func (m *mphf) canonical(key string) string {
+	if m.canon != nil {
+		return m.canon(key)
+	}
+	return key
+}
+
+// LongestPrefix returns the longest key that is a prefix of s, and its jump
+// table index. Only the prefix lengths of stored keys are probed.
+// Returns false if no key is a prefix of s.
+func (m *mphf) LongestPrefix(s string) (key string, index int, ok bool) {
+	for i := len(m.lengths) - 1; i >= 0; i-- {
+		n := m.lengths[i]
+		if n > len(s) {
+			continue
+		}
+		if ix, ok := m.Lookup(s[:n]); ok {
+			return s[:n], ix, true
+		}
+	}
+	return "", -1, false
+}
+
+// Entry is a key and its jump table index
+type Entry struct {
+	Key   string
+	Index int
+}
+
+// SortedEntries returns the keys and their jump table indices, ordered by
+// index. The order depends only on the hash function, not on the order of the
+// keys given to findMPHF, so generated code is stable across rebuilds.
+func (m *mphf) SortedEntries() []Entry {
+	var entries []Entry
+	for ix, e := range m.jmpTab {
+		if e.valid {
+			entries = append(entries, Entry{e.key, ix})
+		}
+	}
+	return entries
+}
+
+// All returns an iterator over the keys and their jump table indices, ordered
+// by index like SortedEntries.
+func (m *mphf) All() iter.Seq2[string, int] {
+	return func(yield func(string, int) bool) {
+		for ix, e := range m.jmpTab {
+			if e.valid && !yield(e.key, ix) {
+				return
+			}
+		}
+	}
+}
+
+// DenseIndex returns the rank of key among the keys in jump table order, an
+// index in [0, N) for N keys. Unlike the jump table index, it leaves no gaps,
+// so values can be packed in an array of N elements. The ranks change when
+// keys are added or removed. Returns -1, false if key is not in the mphf.
+func (m *mphf) DenseIndex(key string) (int, bool) {
+	ix, ok := m.Lookup(key)
+	if !ok {
+		return -1, false
+	}
+	return int(m.rank[ix]), true
+}
+
+// Indices returns a map from each key to its jump table index. SortedEntries
+// and All give the same pairs without building a map.
+func (m *mphf) Indices() map[string]int {
+	indices := make(map[string]int)
+	for key, ix := range m.All() {
+		indices[key] = ix
+	}
+	return indices
+}
+
+// BucketShifts returns a copy of the shift values. The index is the bucket
+// number, sum & bktMask, of the fnv hash sum of a key.
+func (m *mphf) BucketShifts() []byte {
+	if m.packed == nil {
+		return append([]byte(nil), m.bktShift...)
+	}
+	shifts := make([]byte, m.bktMask+1)
+	for bkt := range shifts {
+		shifts[bkt] = m.shiftAt(uint32(bkt))
+	}
+	return shifts
+}
+
+// BucketOf returns the bucket number of key, the index of its shift value
+// in BucketShifts.
+func (m *mphf) BucketOf(key string) uint32 {
+	return m.fnv.hashString(m.canonical(key)) & m.bktMask
+}
+
+// NumBuckets returns the number of buckets, the length of BucketShifts.
+func (m *mphf) NumBuckets() int {
+	return int(m.bktMask) + 1
+}
+
+// Equal reports whether m and other are the same hash function over the same
+// keys: the fnv parameters, masks, shifts and the set of valid keys match.
+func (m *mphf) Equal(other *mphf) bool {
+	if m.fnv.offset != other.fnv.offset || m.fnv.strlen != other.fnv.strlen {
+		return false
+	}
+	if m.fnv.suffix != other.fnv.suffix || m.fnv.noLength != other.fnv.noLength {
+		return false
+	}
+	if (m.fnv.lens == nil) != (other.fnv.lens == nil) {
+		return false
+	}
+	if m.fnv.lens != nil && *m.fnv.lens != *other.fnv.lens {
+		return false
+	}
+	if (m.fnv.positions == nil) != (other.fnv.positions == nil) {
+		return false
+	}
+	if m.fnv.positions != nil && !reflect.DeepEqual(*m.fnv.positions, *other.fnv.positions) {
+		return false
+	}
+	if m.bktMask != other.bktMask || m.jmpMask != other.jmpMask || m.noXor != other.noXor {
+		return false
+	}
+	if !bytes.Equal(m.BucketShifts(), other.BucketShifts()) {
+		return false
+	}
+
+	keys := make(map[string]struct{})
+	for _, e := range m.jmpTab {
+		if e.valid {
+			keys[e.key] = struct{}{}
+		}
+	}
+	n := 0
+	for _, e := range other.jmpTab {
+		if e.valid {
+			if _, ok := keys[e.key]; !ok {
+				return false
+			}
+			n++
+		}
+	}
+	return n == len(keys)
 }
 
 // newMPHF returns a near minimal perfect hash function for the data set.
 // Returns false if it was not possible to construct the mphf with this fnv
 // hash function.
-func newMPHF(cases []string, fnv fnv1a) (*mphf, bool) {
+func newMPHF(cases []string, fnv fnv1a, opts Options) (*mphf, bool) {
+	sums := make([]uint32, len(cases))
+	for i, str := range cases {
+		sums[i] = fnv.hashString(str)
+	}
+	return newMPHFSums(cases, sums, fnv, opts)
+}
+
+// newMPHFSums is newMPHF with the hash sums of the cases already computed
+func newMPHFSums(cases []string, sums []uint32, fnv fnv1a, opts Options) (*mphf, bool) {
+	m, _, ok := newMPHFInfo(cases, sums, fnv, opts)
+	return m, ok
+}
+
+// buildInfo describes the jump table of a new mphf
+type buildInfo struct {
+	tableSize int // number of jump table slots
+	valid     int // number of occupied slots
+	wasted    int // number of empty slots
+	buckets   int
+}
+
+// newMPHFInfo is newMPHFSums, and also returns the buildInfo of the mphf
+func newMPHFInfo(cases []string, sums []uint32, fnv fnv1a, opts Options) (*mphf, buildInfo, bool) {
+	var info buildInfo
+	if opts.MaxTableSize > 0 && jmpSize(len(cases), opts) > opts.MaxTableSize {
+		return nil, info, false
+	}
 	var m mphf
 	m.fnv = fnv
+	m.initTables(len(cases), opts)
+	m.jmpTab = make([]jmpEntry, m.jmpMask+1)
 
-	// Desired jump table size is the smallest power of 2 greater than N
-	jmpSize := 1
-	for jmpSize <= len(cases) {
-		jmpSize <<= 1
+	ok := m.initBuckets(sums, opts.ReservedSlots, nil, opts.UnsortedBuckets)
+	if !ok && opts.TryNoXor {
+		m.noXor = true
+		ok = m.initBuckets(sums, opts.ReservedSlots, nil, opts.UnsortedBuckets)
+	}
+	if !ok {
+		return nil, info, false
 	}
-	m.jmpTab = make([]jmpEntry, jmpSize)
-	m.jmpMask = uint32(jmpSize - 1)
+
+	for i, str := range cases {
+		m.jmpTab[m.jmpIx(sums[i], m.bktShift[sums[i]&m.bktMask])] = jmpEntry{str, true}
+	}
+	m.initKeys()
+	m.initReserved(opts.ReservedSlots)
+
+	info.tableSize = len(m.jmpTab)
+	info.valid = len(cases)
+	info.wasted = info.tableSize - info.valid
+	info.buckets = len(m.bktShift)
+	if opts.PackShifts {
+		m.packShifts()
+	}
+	return &m, info, true
+}
+
+// shiftsPerWord is the number of 5-bit shift values packed in an uint64
+const shiftsPerWord = 12
+
+// shiftAt returns the shift value of bucket bkt
+func (m mphf) shiftAt(bkt uint32) byte {
+	if m.packed != nil {
+		return byte(m.packed[bkt/shiftsPerWord]>>(bkt%shiftsPerWord*5)) & 31
+	}
+	return m.bktShift[bkt]
+}
+
+// setShift sets the shift value of bucket bkt
+func (m *mphf) setShift(bkt uint32, shift byte) {
+	if m.packed != nil {
+		off := bkt % shiftsPerWord * 5
+		w := &m.packed[bkt/shiftsPerWord]
+		*w = *w&^(31<<off) | uint64(shift)<<off
+		return
+	}
+	m.bktShift[bkt] = shift
+}
+
+// packShifts replaces bktShift with packed
+func (m *mphf) packShifts() {
+	m.packed = make([]uint64, (len(m.bktShift)+shiftsPerWord-1)/shiftsPerWord)
+	for bkt, shift := range m.bktShift {
+		m.setShift(uint32(bkt), shift)
+	}
+	m.bktShift = nil
+}
+
+// initKeys sets the fields derived from the keys in the jump table: the
+// distinct key lengths, and the dense rank of each slot
+func (m *mphf) initKeys() {
+	seen := make(map[int]bool)
+	m.lengths = m.lengths[:0]
+	m.rank = make([]int32, len(m.jmpTab))
+	n := int32(0)
+	for ix, e := range m.jmpTab {
+		if !e.valid {
+			continue
+		}
+		if !seen[len(e.key)] {
+			seen[len(e.key)] = true
+			m.lengths = append(m.lengths, len(e.key))
+		}
+		m.rank[ix] = n
+		n++
+	}
+	sort.Ints(m.lengths)
+}
+
+// initReserved sets the reserved slots of m to those of slots that are within
+// the jump table
+func (m *mphf) initReserved(slots []int) {
+	m.reserved = nil
+	for _, ix := range slots {
+		if ix >= 0 && ix < len(m.jmpTab) {
+			m.reserved = append(m.reserved, ix)
+		}
+	}
+	sort.Ints(m.reserved)
+	m.reserved = slices.Compact(m.reserved)
+}
+
+// isReserved reports whether no key may map to jump table slot ix
+func (m *mphf) isReserved(ix uint32) bool {
+	_, found := slices.BinarySearch(m.reserved, int(ix))
+	return found
+}
+
+// initTables sets the jump table size and allocates the buckets for n keys.
+// The jump table itself is left to the caller.
+func (m *mphf) initTables(n int, opts Options) {
+	m.jmpMask = uint32(jmpSize(n, opts) - 1)
 
 	// Desired number of buckets is the smallest power of 2 greater than N/3
 	bucketCnt := 1
-	for bucketCnt <= len(cases)/3 {
+	for bucketCnt <= n/3 {
 		bucketCnt <<= 1
 	}
 	m.bktMask = uint32(bucketCnt - 1)
 	m.bktShift = make([]byte, bucketCnt)
+}
 
-	ok := m.initBuckets(cases)
-	if !ok {
-		return nil, false
+// jmpSize returns the jump table size for n keys. The desired size is the
+// smallest power of 2 greater than N, or N*GrowthFactor to leave room for Add.
+func jmpSize(n int, opts Options) int {
+	size := float64(n)
+	if opts.GrowthFactor > 1 {
+		size *= opts.GrowthFactor
 	}
-
-	for _, str := range cases {
-		m.jmpTab[m.hashString(str)] = jmpEntry{str, true}
+	jmpSize := 1
+	for float64(jmpSize) <= size {
+		jmpSize <<= 1
 	}
-	return &m, true
+	return jmpSize
 }
 
-// initBuckets initializes the bktShift for each bucket.
+// initBuckets initializes the bktShift for each bucket from the hash sums of
+// the keys, leaving the reserved jump table slots empty.
+// If weights is set, weights[i] is the weight of sums[i]. Heavier buckets are
+// then placed first among buckets of the same size, and each bucket takes the
+// valid shift value with the least weighted sum of jump table indices, rather
+// than the first one. If unsorted is set, the buckets are placed in bucket
+// order instead, see Options.UnsortedBuckets.
 // Returns true if we found good shift values for all buckets.
-func (m *mphf) initBuckets(cases []string) bool {
-	// Populate the hash sums into buckets
+func (m *mphf) initBuckets(sums []uint32, reserved []int, weights []int, unsorted bool) bool {
+	// Populate the hash sums into buckets, and list the non-empty buckets in
+	// the order of their first key
 	buckets := make([][]uint32, len(m.bktShift))
-	for _, str := range cases {
-		sum := m.fnv.hashString(str)
-		buckets[sum&m.bktMask] = append(buckets[sum&m.bktMask], sum)
+	var order []uint32
+	for _, sum := range sums {
+		bkt := sum & m.bktMask
+		if len(buckets[bkt]) == 0 {
+			order = append(order, bkt)
+		}
+		buckets[bkt] = append(buckets[bkt], sum)
+	}
+	var weight map[uint32]int
+	bktWeight := make([]int, len(buckets))
+	if weights != nil {
+		weight = make(map[uint32]int, len(sums))
+		for i, w := range weights {
+			weight[sums[i]] = w
+			bktWeight[sums[i]&m.bktMask] += w
+		}
 	}
 
-	// Sort by bucket size, largest first
-	sort.Slice(buckets, func(i, j int) bool {
-		return len(buckets[i]) > len(buckets[j])
-	})
+	if unsorted {
+		sort.Slice(order, func(i, j int) bool { return order[i] < order[j] })
+	} else {
+		// Sort by bucket size, largest first. Buckets of equal size keep the
+		// order of the keys, so reordering the keys changes the search.
+		sort.SliceStable(order, func(i, j int) bool {
+			a, b := order[i], order[j]
+			if len(buckets[a]) != len(buckets[b]) {
+				return len(buckets[a]) > len(buckets[b])
+			}
+			return bktWeight[a] > bktWeight[b]
+		})
+	}
 
 	// Find a shift value for each bucket
-	hasJump := make([]bool, len(m.jmpTab))
-	for _, sums := range buckets {
-		if len(sums) == 0 {
-			break
+	jmpSize := int(m.jmpMask) + 1
+	hasJump := make([]bool, jmpSize)
+	for _, ix := range reserved {
+		if ix >= 0 && ix < jmpSize {
+			hasJump[ix] = true
 		}
+	}
+	for _, bkt := range order {
+		sums := buckets[bkt]
 
 		// Find a shift value for this bucket so that all sums in this bucket
 		// avoid collisions in the jump table.
 		foundShift := false
+		bestCost := 0
 		for shift := byte(0); shift < 32; shift++ {
 			shiftOk := true
-			newJump := make([]bool, len(m.jmpTab))
+			newJump := make([]bool, jmpSize)
+			cost := 0
 
 			// Try placing sums in the jump table
 			for _, sum := range sums {
@@ -264,23 +1301,25 @@ func (m *mphf) initBuckets(cases []string) bool {
 					break
 				}
 				newJump[ix] = true
+				cost += weight[sum] * int(ix)
 			}
 
-			if shiftOk {
+			if shiftOk && (!foundShift || cost < bestCost) {
 				// Found a valid shift value for this bucket
 				foundShift = true
-				m.bktShift[sums[0]&m.bktMask] = shift
-				for ix, addJump := range newJump {
-					if addJump {
-						hasJump[ix] = true
-					}
+				bestCost = cost
+				m.bktShift[bkt] = shift
+				if weights == nil {
+					break
 				}
-				break
 			}
 		}
 		if !foundShift {
 			return false
 		}
+		for _, sum := range sums {
+			hasJump[m.jmpIx(sum, m.bktShift[bkt])] = true
+		}
 	}
 	return true
 }
@@ -290,23 +1329,265 @@ type jmpEntry struct {
 	valid bool
 }
 
-func main() {
-	var mphfs int
-	var successCnt int
-	var total int
+// EstimateSuccess returns a rough estimate in [0,1] of the probability that
+// findMPHF succeeds for cases within its attempts at each strlen.
+//
+// FNV-1a mixes each byte only into the higher bits of the sum, so the low bits
+// that select the bucket depend only on the low bits of the length byte and of
+// the key bytes. Keys of the same length that agree in those bits share a
+// bucket at every seed, which makes densely length-aliased sets hard. The
+// estimate therefore counts the keys per bucket for a few seeds, rather than
+// assuming random bucket sizes, and models initBuckets on those counts: the
+// buckets are placed largest first, and each has 32 shift values to find free
+// jump table slots.
+func EstimateSuccess(cases []string) float64 {
+	return estimateSuccess(cases, Options{})
+}
 
+// estimateSeeds is the number of seeds whose bucket counts EstimateSuccess
+// averages
+const estimateSeeds = 8
+
+// estimateSuccess is EstimateSuccess for the search of findMPHFOptions with
+// opts, with FixedStrLen, Attempts and GrowthFactor.
+func estimateSuccess(cases []string, opts Options) float64 {
+	cases = Deduplicate(append([]string(nil), cases...))
+	if len(cases) == 0 {
+		return 1
+	}
+	first, last := MinInputLen(cases), 0
+	for _, str := range cases {
+		last = max(last, len(str))
+	}
+	if opts.FixedStrLen > 0 {
+		if opts.FixedStrLen < first {
+			return 0
+		}
+		first, last = opts.FixedStrLen, opts.FixedStrLen
+	}
+
+	var m mphf
+	m.initTables(len(cases), opts)
+	slots := jmpSize(len(cases), opts)
+	tmpl := hashTemplate(cases, first, opts)
+	sums := make([]uint32, len(cases))
+	p := 0.0
+	for seed := uint32(1); seed <= estimateSeeds; seed++ {
+		if !hashSums(cases, tmpl.withSeed(seed), sums) {
+			// A seed with colliding sums fails
+			continue
+		}
+		sizes := make([]int, len(m.bktShift))
+		for _, sum := range sums {
+			sizes[sum&m.bktMask]++
+		}
+		slices.SortFunc(sizes, func(a, b int) int { return b - a })
+
+		// Probability that every bucket finds a shift, largest buckets first
+		logP := 0.0
+		placed := 0
+		for _, size := range sizes {
+			// Probability that a shift puts the keys of the bucket into
+			// distinct free slots
+			free := 1.0
+			for i := 0; i < size; i++ {
+				free *= math.Max(0, 1-float64(placed+i)/float64(slots))
+			}
+			logP += math.Log(1 - math.Pow(1-free, 32))
+			placed += size
+		}
+		p += math.Exp(logP) / estimateSeeds
+	}
+
+	// Each strlen gets the attempts, with new hash sums
+	attempts := (last - first + 1) * opts.attempts()
+	return 1 - math.Pow(1-p, float64(attempts))
+}
+
+// FindMPHFAll tries to find a MPHF for each set of cases. results[i] is the
+// MPHF of sets[i], or nil if none is found, and failed lists the indices of
+// the sets without a MPHF.
+func FindMPHFAll(sets [][]string) (results []*mphf, failed []int) {
+	return findMPHFAll(sets, Options{})
+}
+
+// findMPHFAll is FindMPHFAll with opts
+func findMPHFAll(sets [][]string, opts Options) (results []*mphf, failed []int) {
+	results = make([]*mphf, len(sets))
+	for i, cases := range sets {
+		m, ok := findMPHFOptions(cases, opts)
+		if !ok {
+			failed = append(failed, i)
+			continue
+		}
+		results[i] = m
+	}
+	return results, failed
+}
+
+// countMPHFs tries to find a MPHF for each set of cases
+func countMPHFs(sets [][]string, opts Options) (successCnt, mphfs, total int) {
+	_, failed := findMPHFAll(sets, opts)
+	total = len(sets)
+	successCnt = total - len(failed)
+	return successCnt, successCnt, total
+}
+
+// Config is the input of run
+type Config struct {
+	// Sets are the sets of cases to find a MPHF for
+	Sets [][]string
+
+	// Rand is the source of seeds
+	Rand *rand.Rand
+
+	// SequentialSeeds tries the seeds 1, 2, 3, ... for each set instead
+	SequentialSeeds bool
+}
+
+// Report is the result of run
+type Report struct {
+	Success, MPHFs, Total int // number of sets with a MPHF, and of all sets
+
+	// SuccessRate and MPHFRate are Success and MPHFs in percent of Total
+	SuccessRate, MPHFRate float64
+
+	Duration time.Duration
+}
+
+// run tries to find a MPHF for each set of cfg, and reports the rates. All
+// seeds come from cfg, so the same Config gives the same Report, except for
+// the Duration.
+func run(cfg Config) Report {
+	opts := Options{Rand: cfg.Rand, SequentialSeeds: cfg.SequentialSeeds}
+
+	var r Report
 	start := time.Now()
-	for _, cases := range testcases {
-		_, ok := findMPHF(cases)
-		if ok {
-			successCnt++
-			mphfs++
+	r.Success, r.MPHFs, r.Total = countMPHFs(cfg.Sets, opts)
+	r.Duration = time.Since(start)
+	if r.Total > 0 {
+		r.SuccessRate = 100 * float64(r.Success) / float64(r.Total)
+		r.MPHFRate = 100 * float64(r.MPHFs) / float64(r.Total)
+	}
+	return r
+}
+
+func main() {
+	seed := flag.Int64("seed", 0, "Seed the search with `N` for reproducible rates (0 for a random seed)")
+	sequential := flag.Bool("sequential", false, "Try the seeds 1, 2, 3, ... for each case set")
+	keys := flag.String("keys", "", "Generate a lookup function for the keys in `file`, one per line, instead of reporting the rates")
+	funcName := flag.String("func", "lookup", "Name the generated lookup function `name`")
+	pkg := flag.String("pkg", "main", "Put the generated lookup function in package `name`")
+	out := flag.String("o", "", "Write the generated lookup function to `file` instead of stdout")
+	flag.Parse()
+
+	if *keys != "" {
+		if err := generateKeysFile(*keys, *out, *pkg, *funcName); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
 		}
-		total++
+		return
+	}
+
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
 	}
-	end := time.Now()
+	r := run(Config{
+		Sets:            testcases,
+		Rand:            rand.New(rand.NewSource(*seed)),
+		SequentialSeeds: *sequential,
+	})
+
+	fmt.Printf("Success rate: %.1f%%\n", r.SuccessRate)
+	fmt.Printf("MPHF rate: %.1f%%\n", r.MPHFRate)
+	fmt.Println("Total time:", r.Duration)
+}
 
-	fmt.Printf("Success rate: %.1f%%\n", 100*float64(successCnt)/float64(total))
-	fmt.Printf("MPHF rate: %.1f%%\n", 100*float64(mphfs)/float64(total))
-	fmt.Println("Total time:", end.Sub(start))
+// generateKeysFile reads the keys of keysFile with ReadKeys, and writes their
+// lookup function from GenerateFile to outFile, or to stdout if it is "". The
+// output file is only written if the lookup function is generated.
+func generateKeysFile(keysFile, outFile, pkg, funcName string) error {
+	f, err := os.Open(keysFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	keys, err := ReadKeys(f)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if _, err := GenerateFile(&buf, pkg, funcName, keys); err != nil {
+		return err
+	}
+	if outFile == "" {
+		_, err = os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	return os.WriteFile(outFile, buf.Bytes(), 0644)
+}
+
+// FindMPHFPresorted is findMPHF for cases that are already sorted and
+// distinct, such as the keys of a B-tree, without sorting them again. It
+// panics if the cases are not in strictly increasing order.
+func FindMPHFPresorted(sortedUnique []string) (*mphf, bool) {
+	for i := 1; i < len(sortedUnique); i++ {
+		if sortedUnique[i-1] >= sortedUnique[i] {
+			panic(fmt.Sprintf("FindMPHFPresorted: %q is not before %q", sortedUnique[i-1], sortedUnique[i]))
+		}
+	}
+	m, _, _, ok := searchMPHF(sortedUnique, Options{}.preset())
+	return m, ok
+}
+
+// FindLengthOnlyHash finds a MPHF which hashes only the length byte of the
+// keys, with strlen 0, so that Generate emits a switch on the length with a
+// single comparison per key. Returns false if two keys have the same length
+// modulo 256.
+func FindLengthOnlyHash(cases []string) (*mphf, bool) {
+	var opts Options
+	cases = Deduplicate(append([]string(nil), cases...))
+	if MinInputLen(cases) != 0 {
+		return nil, false
+	}
+	sums := make([]uint32, len(cases))
+	for i := 0; i < opts.attempts(); i++ {
+		seed := opts.seed()
+		fnv := fnv1a{}.withSeed(seed)
+		if !hashSums(cases, fnv, sums) {
+			continue
+		}
+		if m, _, ok := newMPHFInfo(cases, sums, fnv, opts); ok {
+			m.seed = seed
+			return m, true
+		}
+	}
+	return nil, false
+}
+
+// fastAttempts is the number of seeds FindMPHFFast tries, unless
+// opts.Attempts is set
+const fastAttempts = 8
+
+// FindMPHFFast is findMPHFOptions with a budget of fastAttempts seeds, at the
+// minimal strlen, instead of up to maxAttempts seeds per strlen. It fails much
+// sooner on hard sets, and most easy sets succeed within the first few seeds
+// anyway. cases is not modified.
+func FindMPHFFast(cases []string, opts Options) (*mphf, bool) {
+	if opts.Attempts == 0 {
+		opts.Attempts = fastAttempts
+	}
+	opts.minStrlenOnly = true
+	return findMPHFOptions(append([]string(nil), cases...), opts)
+}
+
+// MustFindMPHF is like findMPHF but panics if no MPHF is found. It simplifies
+// initialization of package level variables.
+func MustFindMPHF(cases []string) *mphf {
+	m, ok := findMPHF(cases)
+	if !ok {
+		panic(fmt.Sprintf("findhash: no MPHF found for %d keys", len(cases)))
+	}
+	return m
 }
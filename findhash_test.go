@@ -1,10 +1,16 @@
 package main
 
 import (
+	"bytes"
+	"fmt"
 	"hash/fnv"
 	"hash/maphash"
+	"math"
 	"math/bits"
 	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
 )
 
@@ -21,13 +27,474 @@ func TestHashInputLen(t *testing.T) {
 	}
 
 	for _, tc := range testcases {
-		ul := minInputLen(tc.cases)
+		ul := MinInputLen(tc.cases)
 		if ul != tc.uniqueLen {
 			t.Errorf("got uniqueLen %d, expected %d for %v", ul, tc.uniqueLen, tc.cases)
 		}
 	}
 }
 
+func TestMinInputLen(t *testing.T) {
+	tests := [][]string{
+		{"", "a", "ab"},
+		{"", "ab", "bb"},
+		{"abc", "abd", ""},
+		{"", strings.Repeat("a", 256), "ab"},
+		{"386", "amd64", "arm"},
+		{"ab", "abc", "abd"},
+	}
+	tests = append(tests, testcases...)
+
+	distinct := func(cases []string, strlen int) bool {
+		return len(Deduplicate(prefixes(cases, strlen))) == len(cases)
+	}
+	for _, cases := range tests {
+		orig := append([]string(nil), cases...)
+		n := MinInputLen(cases)
+		if !reflect.DeepEqual(cases, orig) {
+			t.Errorf("MinInputLen modified %q", orig)
+		}
+		if !distinct(cases, n) {
+			t.Errorf("MinInputLen(%q) = %d does not tell the cases apart", cases, n)
+		}
+	}
+}
+
+// prefixes returns the length byte and the first strlen bytes of each case
+func prefixes(cases []string, strlen int) []string {
+	var out []string
+	for _, str := range cases {
+		lb := byte(len(str))
+		if len(str) > strlen {
+			str = str[:strlen]
+		}
+		out = append(out, fmt.Sprintf("%d:%s", lb, str))
+	}
+	return out
+}
+
+func TestCheckLengthAliasing(t *testing.T) {
+	long := "x" + strings.Repeat("-", 259) + "y"
+	cases := []string{long, "short", "other", "ab", strings.Repeat("z", 258)}
+	expected := [][2]string{
+		{"ab", strings.Repeat("z", 258)},
+		{"other", long},
+		{"short", long},
+	}
+	if got := CheckLengthAliasing(cases); !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %q, expected %q", got, expected)
+	}
+	if got := CheckLengthAliasing([]string{"386", "amd64", "arm"}); got != nil {
+		t.Errorf("got %q for keys shorter than 256 bytes", got)
+	}
+}
+
+func TestDeduplicate(t *testing.T) {
+	tests := []struct {
+		data     []string
+		expected []string
+	}{
+		{nil, nil},
+		{[]string{}, []string{}},
+		{[]string{"a"}, []string{"a"}},
+		{[]string{"a", "a", "a"}, []string{"a"}},
+		{[]string{"c", "a", "b"}, []string{"a", "b", "c"}},
+		{[]string{"b", "a", "b", "c", "a"}, []string{"a", "b", "c"}},
+	}
+	for _, tc := range tests {
+		data := append([]string(nil), tc.data...)
+		if tc.data != nil && data == nil {
+			data = []string{}
+		}
+		if got := Deduplicate(data); !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("Deduplicate(%q) = %q, expected %q", tc.data, got, tc.expected)
+		}
+	}
+}
+
+func TestTryNoXor(t *testing.T) {
+	var cases []string
+	for i := 0; i < 15; i++ {
+		cases = append(cases, fmt.Sprintf("key%02d", i))
+	}
+	if _, ok := findMPHFOptions(append([]string(nil), cases...), Options{Rand: rand.New(rand.NewSource(10)), Attempts: 1}); ok {
+		t.Fatal("expected the search to fail with the xor fold")
+	}
+	m, ok := findMPHFOptions(append([]string(nil), cases...), Options{Rand: rand.New(rand.NewSource(10)), Attempts: 1, TryNoXor: true})
+	if !ok {
+		t.Fatal("could not find MPHF with TryNoXor")
+	}
+	if !m.noXor {
+		t.Error("expected the MPHF to record noXor")
+	}
+	for _, key := range cases {
+		if _, ok := m.Lookup(key); !ok {
+			t.Errorf("could not look up %q", key)
+		}
+	}
+
+	data, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m2 mphf
+	if err := m2.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if !m2.noXor || !m.Equal(&m2) {
+		t.Error("noXor did not survive MarshalBinary")
+	}
+
+	var buf bytes.Buffer
+	if err := m.Generate(&buf, "lookup"); err != nil {
+		t.Fatal(err)
+	}
+	checkGenerated(t, m, buf.Bytes(), "lookup", cases)
+}
+
+func TestTryWindows(t *testing.T) {
+	tests := []struct {
+		name             string
+		cases            []string
+		suffix, noLength bool
+	}{
+		// With one byte, only the last one tells these apart
+		{"suffix", []string{"xa", "xb", "xc", "xd"}, true, true},
+		// The last byte and the length byte tell these apart
+		{"suffixlength", []string{"xa", "xxa", "xb"}, true, false},
+		{"prefix", []string{"a", "b", "c"}, false, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := Options{TryWindows: true, FixedStrLen: 1}
+			if _, ok := findMPHFOptions(append([]string(nil), tc.cases...), Options{FixedStrLen: 1}); ok == (tc.suffix || tc.noLength) {
+				t.Errorf("got ok %v without TryWindows", ok)
+			}
+			m, ok := findMPHFOptions(append([]string(nil), tc.cases...), opts)
+			if !ok {
+				t.Fatal("could not find MPHF")
+			}
+			if m.fnv.suffix != tc.suffix || m.fnv.noLength != tc.noLength || m.fnv.HashesLength() == tc.noLength {
+				t.Errorf("got %v, expected suffix %v, no length byte %v", m.fnv, tc.suffix, tc.noLength)
+			}
+			for _, key := range tc.cases {
+				if _, ok := m.Lookup(key); !ok {
+					t.Errorf("could not look up %q", key)
+				}
+			}
+
+			data, err := m.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var decoded mphf
+			if err := decoded.UnmarshalBinary(data); err != nil {
+				t.Fatal(err)
+			}
+			if !m.Equal(&decoded) {
+				t.Error("decoded MPHF differs")
+			}
+
+			var buf bytes.Buffer
+			if err := m.Generate(&buf, "lookup"); err != nil {
+				t.Fatal(err)
+			}
+			checkGenerated(t, m, buf.Bytes(), "lookup", tc.cases)
+		})
+	}
+}
+
+func TestCanonicalize(t *testing.T) {
+	opts := Options{Canonicalize: func(s string) string {
+		return strings.TrimSuffix(s, "/")
+	}}
+	m, ok := findMPHFOptions([]string{"/a", "/a/", "/b/", "/c"}, opts)
+	if !ok {
+		t.Fatal("could not find MPHF")
+	}
+	if n := len(m.keys()); n != 3 {
+		t.Errorf("got %d keys, expected 3", n)
+	}
+
+	for _, pair := range [][2]string{{"/a", "/a/"}, {"/b", "/b/"}, {"/c", "/c/"}} {
+		ix1, ok1 := m.Lookup(pair[0])
+		ix2, ok2 := m.Lookup(pair[1])
+		if !ok1 || !ok2 || ix1 != ix2 {
+			t.Errorf("got %d, %v for %q and %d, %v for %q", ix1, ok1, pair[0], ix2, ok2, pair[1])
+		}
+	}
+	if _, ok := m.Lookup("/d/"); ok {
+		t.Error("found /d/")
+	}
+
+	// ShiftFor canonicalizes key like Lookup
+	var keys []string
+	for i := 0; i < 60; i++ {
+		keys = append(keys, fmt.Sprintf("/k%d/", i))
+	}
+	shifted, ok := findMPHFOptions(append([]string(nil), keys...), opts)
+	if !ok {
+		t.Fatal("could not find MPHF")
+	}
+	for _, key := range keys {
+		ix, ok := shifted.Lookup(key)
+		sum := shifted.fnv.hashString(shifted.canonical(key))
+		if got := shifted.jmpIx(sum, shifted.ShiftFor(key)); !ok || int(got) != ix {
+			t.Errorf("got index %d with ShiftFor(%q), expected %d", got, key, ix)
+		}
+	}
+
+	if !m.Remove("/a/") {
+		t.Fatal("could not remove /a/")
+	}
+	if _, ok := m.Lookup("/a"); ok {
+		t.Error("found /a after removing /a/")
+	}
+}
+
+func TestMustFindMPHF(t *testing.T) {
+	if m := MustFindMPHF([]string{"386", "amd64", "arm"}); m == nil {
+		t.Error("got nil MPHF")
+	}
+
+	// 255 keys fill the jump table of 256 slots, which needs far more
+	// attempts than maxAttempts
+	var cases []string
+	for i := 0; i < 255; i++ {
+		cases = append(cases, fmt.Sprintf("key%06d", i*7919))
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("expected MustFindMPHF to panic")
+		}
+	}()
+	MustFindMPHF(cases)
+}
+
+func TestFindMPHFPresorted(t *testing.T) {
+	for _, cases := range testcases {
+		sorted := Deduplicate(append([]string(nil), cases...))
+		m, ok := FindMPHFPresorted(sorted)
+		if !ok {
+			t.Fatal("could not find MPHF")
+		}
+		expected, ok := newMPHF(Deduplicate(append([]string(nil), cases...)), m.fnv, Options{})
+		if !ok || !m.Equal(expected) {
+			t.Errorf("MPHF of %d presorted cases differs from findMPHF with the same hash", len(sorted))
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for unsorted cases")
+		}
+	}()
+	FindMPHFPresorted([]string{"arm", "amd64"})
+}
+
+func TestFindLengthOnlyHash(t *testing.T) {
+	cases := []string{"", "a", "bb", "ccc", "dddddd", strings.Repeat("e", 255)}
+	m, ok := FindLengthOnlyHash(cases)
+	if !ok {
+		t.Fatal("could not find length-only hash for distinct lengths")
+	}
+	if m.fnv.strlen != 0 {
+		t.Errorf("got strlen %d, expected 0", m.fnv.strlen)
+	}
+	for _, str := range cases {
+		if _, ok := m.Lookup(str); !ok {
+			t.Errorf("key %q not found", str)
+		}
+	}
+	if _, ok := m.Lookup("xx"); ok {
+		t.Error("found an unknown key of a key length")
+	}
+
+	for i, aliased := range [][]string{
+		{"a", "bb", "c"},
+		{"a", "b" + strings.Repeat("-", 256)},
+	} {
+		if _, ok := FindLengthOnlyHash(aliased); ok {
+			t.Errorf("found length-only hash for aliased set %d", i)
+		}
+	}
+}
+
+func TestFindMPHFFast(t *testing.T) {
+	for _, cases := range testcases {
+		orig := append([]string(nil), cases...)
+		m, ok := FindMPHFFast(cases, Options{})
+		if !reflect.DeepEqual(cases, orig) {
+			t.Errorf("FindMPHFFast modified %q", orig)
+		}
+		if !ok {
+			// Not guaranteed, but easy sets practically always succeed
+			t.Errorf("could not find MPHF for %q", cases)
+			continue
+		}
+		for _, key := range cases {
+			if _, ok := m.Lookup(key); !ok {
+				t.Errorf("could not look up %q", key)
+			}
+		}
+	}
+}
+
+func TestFindMPHFFastOptions(t *testing.T) {
+	// The same seeds from Rand give the same MPHF, at the minimal strlen
+	cases := []string{"k00-0", "k01-1", "k02-2", "k03-3", "k04-4", "k05-0", "k06-1"}
+	a, ok := FindMPHFFast(cases, Options{Rand: rand.New(rand.NewSource(1))})
+	if !ok {
+		t.Fatal("could not find MPHF")
+	}
+	b, ok := FindMPHFFast(cases, Options{Rand: rand.New(rand.NewSource(1))})
+	if !ok {
+		t.Fatal("could not find MPHF")
+	}
+	if a.seed != b.seed || !a.Equal(b) {
+		t.Errorf("got seeds %d and %d from the same Rand", a.seed, b.seed)
+	}
+	if n := MinInputLen(cases); a.fnv.strlen != n {
+		t.Errorf("got strlen %d, expected MinInputLen %d", a.fnv.strlen, n)
+	}
+
+	// Attempts seeds are tried at the minimal strlen only
+	var hard []string
+	for i := 0; i < 255; i++ {
+		hard = append(hard, fmt.Sprintf("key%03d-suffix", i))
+	}
+	attempts := 0
+	opts := Options{SequentialSeeds: true, Attempts: 3, progress: func(int) { attempts++ }}
+	if _, ok := FindMPHFFast(hard, opts); ok {
+		t.Fatal("found MPHF for 255 keys in 256 slots")
+	}
+	if attempts != 3 {
+		t.Errorf("made %d attempts, expected 3", attempts)
+	}
+}
+
+func TestExclude(t *testing.T) {
+	cases := []string{"", "386", "amd64", "", "arm"}
+	m, ok := findMPHFOptions(cases, Options{Exclude: []string{""}})
+	if !ok {
+		t.Fatal("could not find MPHF")
+	}
+	if ix, ok := m.Lookup(""); ok {
+		t.Errorf("got index %d for excluded key", ix)
+	}
+	for _, key := range []string{"386", "amd64", "arm"} {
+		if _, ok := m.Lookup(key); !ok {
+			t.Errorf("could not look up %q", key)
+		}
+	}
+	if n := len(m.keys()); n != 3 {
+		t.Errorf("got %d keys, expected 3", n)
+	}
+
+	// Excluded keys are canonicalized like the cases
+	m, ok = findMPHFOptions([]string{"GET", "Post", "put"}, Options{Canonicalize: strings.ToLower, Exclude: []string{"POST"}})
+	if !ok {
+		t.Fatal("could not find MPHF")
+	}
+	if ix, ok := m.Lookup("post"); ok {
+		t.Errorf("got index %d for a key excluded in another case", ix)
+	}
+	if n := len(m.keys()); n != 2 {
+		t.Errorf("got %d keys, expected 2", n)
+	}
+}
+
+func TestLongKeys(t *testing.T) {
+	// a and b have the same length, and a[:44] has the same length byte
+	a := strings.Repeat("x", 300)
+	b := a[:260] + "y" + a[261:]
+	cases := []string{a, b, a[:44], "short"}
+	if n := MinInputLen(append([]string(nil), cases...)); n != 261 {
+		t.Errorf("got MinInputLen %d, expected 261", n)
+	}
+
+	for _, opts := range []Options{{}, {PerLengthStrlen: true}, {SkipConstantBytes: true}} {
+		m, ok := findMPHFOptions(append([]string(nil), cases...), opts)
+		if !ok {
+			t.Fatalf("could not find MPHF with %+v", opts)
+		}
+		for _, key := range cases {
+			if _, ok := m.Lookup(key); !ok {
+				t.Errorf("could not look up %d byte key with %+v", len(key), opts)
+			}
+		}
+		if m.hashString(a) == m.hashString(b) {
+			t.Errorf("a and b collide with %+v", opts)
+		}
+	}
+}
+
+func TestHashesLength(t *testing.T) {
+	// ab and abc hash the same content bytes with strlen 2
+	f := newFnv1a(1, 2)
+	differ := f.hashString("ab") != f.hashString("abc")
+	if f.HashesLength() != differ {
+		t.Errorf("got HashesLength %v, but the length changes the hash: %v", f.HashesLength(), differ)
+	}
+}
+
+func TestFnv1aString(t *testing.T) {
+	f := newFnv1a(1, 3)
+	s := f.String()
+	for _, want := range []string{fmt.Sprintf("offset: %#08x", f.offset), "strlen: 3", "length byte: true"} {
+		if !strings.Contains(s, want) {
+			t.Errorf("%s does not contain %q", s, want)
+		}
+	}
+
+	positions := []int{0, 2}
+	f.positions = &positions
+	if s := f.String(); !strings.Contains(s, "positions: [0 2]") {
+		t.Errorf("%s does not contain the positions", s)
+	}
+}
+
+func TestFindPerfectHash(t *testing.T) {
+	for _, cases := range testcases {
+		orig := append([]string(nil), cases...)
+		f, ok := FindPerfectHash(cases)
+		if !ok {
+			t.Fatal("could not find hash")
+		}
+		if !reflect.DeepEqual(cases, orig) {
+			t.Error("FindPerfectHash modified the cases")
+		}
+		seen := make(map[uint32]string)
+		for _, str := range cases {
+			sum := f.Hash(str)
+			if other, exists := seen[sum]; exists && other != str {
+				t.Errorf("%q and %q have the same hash %#x", str, other, sum)
+			}
+			seen[sum] = str
+		}
+	}
+}
+
+func TestFindHashSeed(t *testing.T) {
+	for _, cases := range testcases {
+		fnv, seed, ok := findHash(cases)
+		if !ok {
+			t.Fatal("could not find hash")
+		}
+		if got := newFnv1a(seed, fnv.strlen); got != fnv {
+			t.Errorf("got %+v from seed %#x, expected %+v", got, seed, fnv)
+		}
+
+		m, ok := findMPHF(cases)
+		if !ok {
+			t.Fatal("could not find MPHF")
+		}
+		if got := newFnv1a(m.seed, m.fnv.strlen); got != m.fnv {
+			t.Errorf("got %+v from seed %#x, expected %+v", got, m.seed, m.fnv)
+		}
+	}
+}
+
 func TestMPHF(t *testing.T) {
 	for _, cases := range testcases {
 		m, ok := findMPHF(cases)
@@ -35,37 +502,838 @@ func TestMPHF(t *testing.T) {
 			t.Fatal("could not find MPHF")
 		}
 
-		// jump table mask and size
-		got := bits.Len32(m.jmpMask)
-		expected := bits.Len32(uint32(len(cases)))
-		if got != expected {
-			t.Errorf("got mask with %d bits, expected %d", got, expected)
+		// jump table mask and size
+		got := bits.Len32(m.jmpMask)
+		expected := bits.Len32(uint32(len(cases)))
+		if got != expected {
+			t.Errorf("got mask with %d bits, expected %d", got, expected)
+		}
+
+		got = bits.OnesCount32(m.jmpMask)
+		if got != expected {
+			t.Errorf("got mask with %d one-bits, expected %d", got, expected)
+		}
+
+		if len(m.jmpTab) <= len(cases) {
+			t.Errorf("jump table must have more entries than the cases it codes")
+		}
+		if len(m.jmpTab) != int(m.jmpMask+1) {
+			t.Errorf("got jump table size %d, expected %d", len(m.jmpTab), m.jmpMask+1)
+		}
+
+		hasHash := make([]bool, len(m.jmpTab))
+		for _, str := range cases {
+			hash := m.hashString(str)
+			if hash >= uint32(len(m.jmpTab)) {
+				t.Errorf("hash(%q)=%d exceeds jump table %d", str, hash, len(m.jmpTab))
+				continue
+			}
+			if hasHash[hash] {
+				t.Errorf("hash collision for %q in %+v", str, cases)
+			}
+			hasHash[hash] = true
+		}
+	}
+}
+
+func TestEstimateSuccess(t *testing.T) {
+	// Distinct lengths and a roomy jump table
+	separated := []string{"a", "bb", "ccc", "dddd", "eeeee"}
+	if p := EstimateSuccess(separated); p < 0.9 {
+		t.Errorf("got estimate %.3f for %v, expected high", p, separated)
+	}
+
+	// The keys almost fill the jump table
+	full := make([]string, 1000)
+	for i := range full {
+		full[i] = fmt.Sprintf("%04d", i)
+	}
+	if p := EstimateSuccess(full); p > 0.1 {
+		t.Errorf("got estimate %.3f for %d keys in 1024 slots, expected low", p, len(full))
+	}
+
+	// Keys of one length whose bytes differ only in case agree in the low
+	// bits of the sum, and crowd a few buckets at every seed
+	aliased, spread := randomKeys(48, 8, "aA"), randomKeys(48, 8, "abcd")
+	if p := EstimateSuccess(aliased); p > 0.1 {
+		t.Errorf("got estimate %.3f for %d keys that differ only in case, expected low", p, len(aliased))
+	}
+	if _, ok := findMPHFOptions(aliased, Options{Rand: rand.New(rand.NewSource(1))}); ok {
+		t.Errorf("found MPHF for %d keys that differ only in case", len(aliased))
+	}
+	if p := EstimateSuccess(spread); p < 0.9 {
+		t.Errorf("got estimate %.3f for %d keys of distinct letters, expected high", p, len(spread))
+	}
+
+	for _, cases := range testcases {
+		if p := EstimateSuccess(cases); p < 0 || p > 1 {
+			t.Errorf("got estimate %f outside [0,1] for %v", p, cases)
+		}
+	}
+}
+
+// randomKeys returns n distinct keys of length strlen with random bytes of
+// alphabet
+func randomKeys(n, strlen int, alphabet string) []string {
+	r := rand.New(rand.NewSource(1))
+	seen := make(map[string]bool)
+	var keys []string
+	for len(keys) < n {
+		b := make([]byte, strlen)
+		for i := range b {
+			b[i] = alphabet[r.Intn(len(alphabet))]
+		}
+		if !seen[string(b)] {
+			seen[string(b)] = true
+			keys = append(keys, string(b))
+		}
+	}
+	return keys
+}
+
+func TestEstimateSuccessOutcomes(t *testing.T) {
+	// With a single attempt at a fixed strlen, the estimate is the
+	// probability that one seed succeeds. Compare it with the success rate
+	// over seeds of the testcases.
+	const seeds = 20
+	var lowEstimate, lowRate, highRate float64
+	var low, high int
+	for _, cases := range testcases {
+		opts := Options{Attempts: 1, FixedStrLen: max(1, MinInputLen(Deduplicate(append([]string(nil), cases...))))}
+		p := estimateSuccess(cases, opts)
+		n := 0
+		for seed := int64(1); seed <= seeds; seed++ {
+			opts.Rand = rand.New(rand.NewSource(seed))
+			if _, ok := findMPHFOptions(append([]string(nil), cases...), opts); ok {
+				n++
+			}
+		}
+		rate := float64(n) / seeds
+		if p < 0.99 {
+			low++
+			lowEstimate += p
+			lowRate += rate
+		} else {
+			high++
+			highRate += rate
+		}
+	}
+	if low == 0 || high == 0 {
+		t.Fatalf("got %d sets with low and %d with high estimates", low, high)
+	}
+	lowEstimate, lowRate, highRate = lowEstimate/float64(low), lowRate/float64(low), highRate/float64(high)
+	if lowRate >= highRate {
+		t.Errorf("sets with low estimates succeed in %.3f of the seeds, those with high estimates in %.3f", lowRate, highRate)
+	}
+	if math.Abs(lowEstimate-lowRate) > 0.05 {
+		t.Errorf("got mean estimate %.3f for the sets with low estimates, which succeed in %.3f of the seeds", lowEstimate, lowRate)
+	}
+}
+
+func TestShufflePerAttempt(t *testing.T) {
+	// 63 keys almost fill a jump table of 64 entries
+	cases := make([]string, 63)
+	for i := range cases {
+		cases[i] = fmt.Sprintf("key%02d", i)
+	}
+
+	// Sequential seeds are not drawn from Rand, so both searches hash with
+	// the same seeds, and only the order of the cases differs. The first
+	// seed fails, and the second one succeeds for the sorted cases.
+	opts := Options{SequentialSeeds: true, FixedStrLen: 5, Attempts: 10}
+	_, _, sorted, ok := findMPHFCount(append([]string(nil), cases...), opts)
+	if !ok || sorted != 2 {
+		t.Fatalf("got %d attempts, %v without shuffling, expected 2", sorted, ok)
+	}
+
+	opts.ShufflePerAttempt = true
+	opts.Rand = rand.New(rand.NewSource(1))
+	m, _, shuffled, ok := findMPHFCount(append([]string(nil), cases...), opts)
+	if !ok {
+		t.Fatal("could not find MPHF with shuffling")
+	}
+	if shuffled == sorted {
+		t.Errorf("got %d attempts with and without shuffling, expected the order to change the search", shuffled)
+	}
+	for _, str := range cases {
+		if e := m.jmpTab[m.hashString(str)]; !e.valid || e.key != str {
+			t.Errorf("got entry %+v for %q", e, str)
+		}
+	}
+}
+
+func TestStrlenGrowth(t *testing.T) {
+	cases := make([]string, 63)
+	for i := range cases {
+		cases[i] = fmt.Sprintf("k%02d-%d", i, i%5)
+	}
+	minLen := MinInputLen(append([]string(nil), cases...))
+
+	// With this seed and a single attempt per strlen, the search fails at
+	// MinInputLen and succeeds when hashing more bytes
+	m, ok := findMPHFOptions(cases, Options{Rand: rand.New(rand.NewSource(3)), Attempts: 1})
+	if !ok {
+		t.Fatal("could not find MPHF")
+	}
+	if m.fnv.strlen <= minLen {
+		t.Errorf("got strlen %d, expected more than MinInputLen %d", m.fnv.strlen, minLen)
+	}
+	for _, str := range cases {
+		if e := m.jmpTab[m.hashString(str)]; !e.valid || e.key != str {
+			t.Errorf("got entry %+v for %q", e, str)
+		}
+	}
+}
+
+func TestFindSumsWithAttempts(t *testing.T) {
+	// Equal keys collide at every seed. The search makes opts.attempts()
+	// attempts per strlen, so each must not try maxAttempts seeds on top.
+	opts := Options{Attempts: 3, SequentialSeeds: true}.preset()
+	if _, _, _, ok := findSumsWith([]string{"a", "a"}, fnv1a{}, opts); ok {
+		t.Fatal("found sums without collisions for equal keys")
+	}
+	if *opts.lastSeed != 3 {
+		t.Errorf("tried %d seeds, expected 3", *opts.lastSeed)
+	}
+}
+
+func TestFixedStrLen(t *testing.T) {
+	cases := []string{"ab", "cd", "ef", "gh"}
+	added := append([]string{"ax"}, cases...)
+	if MinInputLen(cases) == MinInputLen(added) {
+		t.Fatal("expected the added key to change MinInputLen")
+	}
+
+	for _, keys := range [][]string{cases, added} {
+		m, ok := findMPHFOptions(append([]string(nil), keys...), Options{FixedStrLen: 3})
+		if !ok {
+			t.Fatalf("could not find MPHF for %q", keys)
+		}
+		if m.fnv.strlen != 3 {
+			t.Errorf("got strlen %d for %q, expected 3", m.fnv.strlen, keys)
+		}
+		for _, key := range keys {
+			if _, ok := m.Lookup(key); !ok {
+				t.Errorf("could not look up %q", key)
+			}
+		}
+	}
+
+	if _, ok := findMPHFOptions(append([]string(nil), added...), Options{FixedStrLen: 1}); ok {
+		t.Error("found MPHF with FixedStrLen below MinInputLen")
+	}
+}
+
+func TestLookup(t *testing.T) {
+	cases := []string{"386", "amd64", "arm", "arm64", "ppc64", "wasm"}
+	m, ok := findMPHF(append([]string(nil), cases...))
+	if !ok {
+		t.Fatal("could not find MPHF")
+	}
+
+	for _, key := range cases {
+		ix, ok := m.Lookup(key)
+		if !ok || m.jmpTab[ix].key != key {
+			t.Errorf("Lookup(%q) = %d, %v", key, ix, ok)
+		}
+		if !m.MayContain(key) {
+			t.Errorf("MayContain(%q) = false for a key", key)
+		}
+	}
+
+	// Find an unknown string which hashes to an occupied slot
+	for i := 0; ; i++ {
+		unknown := fmt.Sprintf("unknown%d", i)
+		if !m.MayContain(unknown) {
+			if _, ok := m.Lookup(unknown); ok {
+				t.Errorf("Lookup(%q) found a string in an empty slot", unknown)
+			}
+			continue
+		}
+		if ix, ok := m.Lookup(unknown); ok {
+			t.Errorf("Lookup(%q) = %d, expected not found", unknown, ix)
+		}
+		break
+	}
+}
+
+func TestBucketShifts(t *testing.T) {
+	for _, cases := range testcases {
+		m, ok := findMPHF(cases)
+		if !ok {
+			t.Fatal("could not find MPHF")
+		}
+
+		shifts := m.BucketShifts()
+		if len(shifts) != int(m.bktMask+1) {
+			t.Errorf("got %d shifts, expected %d buckets", len(shifts), m.bktMask+1)
+		}
+		for _, str := range cases {
+			sum := m.fnv.hashString(str)
+			if m.jmpIx(sum, shifts[sum&m.bktMask]) != m.hashString(str) {
+				t.Errorf("shift of bucket %d does not give the index of %q", sum&m.bktMask, str)
+			}
+		}
+
+		for i := range shifts {
+			shifts[i]++
+		}
+		for i := range shifts {
+			if m.bktShift[i] == shifts[i] {
+				t.Fatal("modifying the returned shifts modified the MPHF")
+			}
+		}
+	}
+}
+
+func TestBucketOf(t *testing.T) {
+	for _, cases := range testcases {
+		m, ok := findMPHF(cases)
+		if !ok {
+			t.Fatal("could not find MPHF")
+		}
+		if m.NumBuckets() != len(m.BucketShifts()) {
+			t.Errorf("NumBuckets() = %d, expected %d", m.NumBuckets(), len(m.BucketShifts()))
+		}
+		for _, str := range append([]string{"", "unknown"}, cases...) {
+			bkt := m.BucketOf(str)
+			if int(bkt) >= m.NumBuckets() {
+				t.Errorf("BucketOf(%q) = %d, expected less than %d", str, bkt, m.NumBuckets())
+			}
+			if sum := m.fnv.hashString(str); bkt != sum&m.bktMask {
+				t.Errorf("BucketOf(%q) = %d, expected %d", str, bkt, sum&m.bktMask)
+			}
+		}
+	}
+}
+
+func TestFallbackHash(t *testing.T) {
+	// With 200000 random cases, a 32-bit hash is likely to collide
+	r := rand.New(rand.NewSource(1))
+	var cases []string
+	for i := 0; i < 200000; i++ {
+		cases = append(cases, fmt.Sprintf("%016x", r.Uint64()))
+	}
+	fnv := fallbackHash(cases)
+	if !reflect.DeepEqual(fnv, fallbackHash(cases)) {
+		t.Fatal("fallbackHash is not reproducible")
+	}
+	if fnv.offset != newFnv1a(0, fnv.strlen).offset {
+		t.Errorf("got offset %#x, expected the offset of seed 0", fnv.offset)
+	}
+
+	a, b, ok := collisionPair(cases, fnv)
+	if !ok {
+		t.Fatal("expected a collision")
+	}
+	if a == b || fnv.hashString(a) != fnv.hashString(b) {
+		t.Errorf("%q and %q do not collide", a, b)
+	}
+	if !hasCollisions(cases, fnv) {
+		t.Error("hasCollisions disagrees with collisionPair")
+	}
+
+	small := []string{"386", "amd64", "arm"}
+	if a, b, ok := collisionPair(small, fallbackHash(small)); ok {
+		t.Errorf("unexpected collision of %q and %q", a, b)
+	}
+}
+
+func TestReservedSlots(t *testing.T) {
+	for _, cases := range testcases {
+		m, ok := findMPHFOptions(append([]string(nil), cases...), Options{ReservedSlots: []int{0}})
+		if !ok {
+			t.Fatalf("could not find MPHF for %d cases", len(cases))
+		}
+		if m.jmpTab[0].valid {
+			t.Errorf("key %q maps to reserved slot 0", m.jmpTab[0].key)
+		}
+		for _, str := range cases {
+			if ix, ok := m.Lookup(str); !ok || ix == 0 {
+				t.Errorf("Lookup(%q) = %d, %v", str, ix, ok)
+			}
+		}
+	}
+
+	// Reserving every slot leaves no room for the keys
+	cases := []string{"386", "amd64", "arm"}
+	if _, ok := findMPHFOptions(cases, Options{ReservedSlots: []int{0, 1, 2, 3}}); ok {
+		t.Error("found MPHF with all slots reserved")
+	}
+
+	// Add never fills a reserved slot, also after a round trip through
+	// MarshalBinary
+	m, ok := findMPHFOptions([]string{"k0", "k1", "k2", "k3"}, Options{ReservedSlots: []int{0, 3, 100}, GrowthFactor: 4})
+	if !ok {
+		t.Fatal("could not find MPHF")
+	}
+	data, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded mphf
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range []*mphf{m, &decoded} {
+		if !reflect.DeepEqual(m.reserved, []int{0, 3}) {
+			t.Errorf("got reserved slots %v, expected [0 3]", m.reserved)
+		}
+		added := 0
+		for i := 4; i < 100; i++ {
+			if m.Add(fmt.Sprintf("k%d", i)) {
+				added++
+			}
+			if m.jmpTab[0].valid || m.jmpTab[3].valid {
+				t.Fatalf("Add filled a reserved slot after adding %d keys", added)
+			}
+		}
+		if added == 0 {
+			t.Error("could not add any keys")
+		}
+	}
+}
+
+func TestPossibleMember(t *testing.T) {
+	cases := []string{"386", "amd64", "arm", "arm64", "wasm"}
+	m, ok := findMPHF(append([]string(nil), cases...))
+	if !ok {
+		t.Fatal("could not find MPHF")
+	}
+	for _, str := range append(cases, "ppc", "mips") {
+		if !m.PossibleMember(str) {
+			t.Errorf("PossibleMember(%q) = false, expected true", str)
+		}
+	}
+
+	// Without the tables, hashing would panic
+	probe := *m
+	probe.bktShift, probe.packed, probe.jmpTab = nil, nil, nil
+	for _, str := range []string{"", "x", "mips64", "riscv64"} {
+		if probe.PossibleMember(str) {
+			t.Errorf("PossibleMember(%q) = true, expected false", str)
+		}
+	}
+}
+
+func TestKeyLengths(t *testing.T) {
+	for _, cases := range testcases {
+		m, ok := findMPHF(cases)
+		if !ok {
+			t.Fatal("could not find MPHF")
+		}
+		seen := make(map[int]bool)
+		var expected []int
+		for _, str := range cases {
+			if !seen[len(str)] {
+				seen[len(str)] = true
+				expected = append(expected, len(str))
+			}
+		}
+		sort.Ints(expected)
+
+		lengths := m.KeyLengths()
+		if !reflect.DeepEqual(lengths, expected) {
+			t.Errorf("got lengths %v, expected %v", lengths, expected)
+		}
+		if len(lengths) > 0 {
+			lengths[0]++
+			if m.KeyLengths()[0] == lengths[0] {
+				t.Error("modifying the returned lengths modified the MPHF")
+			}
+		}
+	}
+}
+
+func TestSequentialSeeds(t *testing.T) {
+	for _, cases := range testcases {
+		var encoded [][]byte
+		for i := 0; i < 2; i++ {
+			m, ok := findMPHFOptions(append([]string(nil), cases...), Options{SequentialSeeds: true})
+			if !ok {
+				t.Fatal("could not find MPHF")
+			}
+			data, err := m.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			encoded = append(encoded, data)
+		}
+		if !bytes.Equal(encoded[0], encoded[1]) {
+			t.Errorf("builds of %d cases differ", len(cases))
+		}
+	}
+
+	_, seed, ok := findHashOptions([]string{"386", "amd64", "arm"}, Options{SequentialSeeds: true})
+	if !ok || seed != 1 {
+		t.Errorf("got seed %d, %v, expected 1", seed, ok)
+	}
+}
+
+func TestPackShifts(t *testing.T) {
+	var cases []string
+	for i := 0; i < 600; i++ {
+		cases = append(cases, fmt.Sprintf("key%d", i))
+	}
+	m, ok := findMPHFOptions(append([]string(nil), cases...), Options{Rand: rand.New(rand.NewSource(1))})
+	if !ok {
+		t.Fatal("could not find MPHF")
+	}
+	packed, ok := findMPHFOptions(append([]string(nil), cases...), Options{Rand: rand.New(rand.NewSource(1)), PackShifts: true})
+	if !ok {
+		t.Fatal("could not find packed MPHF")
+	}
+
+	if packed.bktShift != nil {
+		t.Error("packed MPHF keeps the unpacked shifts")
+	}
+	if !m.Equal(packed) {
+		t.Error("packed MPHF differs from the unpacked one")
+	}
+	for _, str := range append(cases, "unknown", "") {
+		ix, ok := m.Lookup(str)
+		pix, pok := packed.Lookup(str)
+		if ix != pix || ok != pok {
+			t.Errorf("Lookup(%q) = %d, %v packed, %d, %v unpacked", str, pix, pok, ix, ok)
+		}
+	}
+	if size := 8 * len(packed.packed); size >= len(m.bktShift) {
+		t.Errorf("packed shifts use %d bytes, unpacked %d", size, len(m.bktShift))
+	}
+}
+
+// avgBytesHashed returns the average number of content bytes hashed per case
+func avgBytesHashed(f fnv1a, cases []string) float64 {
+	total := 0
+	for _, str := range cases {
+		total += f.inputLen(str)
+	}
+	return float64(total) / float64(len(cases))
+}
+
+func TestPerLengthStrlen(t *testing.T) {
+	// Short keys are told apart by their length, long keys need 6 bytes
+	cases := []string{"a", "bb", "ccc", "TrimPrefix", "TrimSuffix", "TrimString"}
+	m, ok := findMPHFOptions(append([]string(nil), cases...), Options{PerLengthStrlen: true})
+	if !ok {
+		t.Fatal("could not find MPHF")
+	}
+	for _, str := range cases {
+		if _, ok := m.Lookup(str); !ok {
+			t.Errorf("Lookup(%q) not found", str)
+		}
+	}
+	if got := m.fnv.inputLen("ccc"); got != 0 {
+		t.Errorf("got %d bytes hashed for %q, expected 0", got, "ccc")
+	}
+	if got := m.fnv.inputLen("TrimPrefix"); got != 6 {
+		t.Errorf("got %d bytes hashed for %q, expected 6", got, "TrimPrefix")
+	}
+
+	for _, cases := range testcases {
+		global, ok := findMPHF(cases)
+		if !ok {
+			t.Fatal("could not find MPHF")
+		}
+		perLen, ok := findMPHFOptions(cases, Options{PerLengthStrlen: true})
+		if !ok {
+			t.Fatal("could not find MPHF with PerLengthStrlen")
+		}
+		if hasCollisions(cases, perLen.fnv) {
+			t.Errorf("hash collision in %v", cases)
+		}
+		for _, str := range cases {
+			if _, ok := perLen.Lookup(str); !ok {
+				t.Errorf("Lookup(%q) not found", str)
+			}
+		}
+		if g, p := avgBytesHashed(global.fnv, cases), avgBytesHashed(perLen.fnv, cases); p > g {
+			t.Errorf("got %.1f bytes hashed per key, expected at most %.1f for %v", p, g, cases)
+		}
+	}
+}
+
+func TestLongestPrefix(t *testing.T) {
+	m, ok := findMPHF([]string{"/a", "/a/b"})
+	if !ok {
+		t.Fatal("could not find MPHF")
+	}
+
+	tests := []struct {
+		s   string
+		key string
+		ok  bool
+	}{
+		{"/a/b/c", "/a/b", true},
+		{"/a/b", "/a/b", true},
+		{"/a/", "/a", true},
+		{"/a", "/a", true},
+		{"/", "", false},
+		{"/b/a", "", false},
+		{"", "", false},
+	}
+	for _, tc := range tests {
+		key, ix, ok := m.LongestPrefix(tc.s)
+		if key != tc.key || ok != tc.ok {
+			t.Errorf("LongestPrefix(%q) = %q, %v, expected %q, %v", tc.s, key, ok, tc.key, tc.ok)
+		}
+		if ok && ix != int(m.hashString(key)) {
+			t.Errorf("LongestPrefix(%q) returned index %d, expected %d", tc.s, ix, m.hashString(key))
+		}
+	}
+}
+
+func TestOptimize(t *testing.T) {
+	for _, cases := range testcases {
+		speed, ok := findMPHFOptions(cases, Options{Optimize: OptimizeSpeed})
+		if !ok {
+			t.Fatal("could not find MPHF optimized for speed")
+		}
+		size, ok := findMPHFOptions(cases, Options{Optimize: OptimizeSize, GrowthFactor: 4})
+		if !ok {
+			t.Fatal("could not find MPHF optimized for size")
+		}
+
+		if len(speed.jmpTab) <= len(size.jmpTab) {
+			t.Errorf("got jump table size %d for speed, expected more than %d for size", len(speed.jmpTab), len(size.jmpTab))
+		}
+		if bits.Len32(size.jmpMask) != bits.Len32(uint32(len(cases))) {
+			t.Errorf("got jump table size %d for size, expected minimal for %d keys", len(size.jmpTab), len(cases))
+		}
+		if speed.fnv.lens == nil {
+			t.Error("expected PerLengthStrlen for speed")
+		}
+		for _, str := range cases {
+			if _, ok := speed.Lookup(str); !ok {
+				t.Errorf("Lookup(%q) not found when optimized for speed", str)
+			}
+			if _, ok := size.Lookup(str); !ok {
+				t.Errorf("Lookup(%q) not found when optimized for size", str)
+			}
+		}
+	}
+}
+
+func TestSkipConstantBytes(t *testing.T) {
+	// Constant prefix and middle: only offsets 4 and 6 vary
+	cases := []string{"img-a-b.png", "img-a-c.png", "img-b-b.png", "img-b-c.png", "img-c-a.png"}
+	m, ok := findMPHFOptions(append([]string(nil), cases...), Options{SkipConstantBytes: true})
+	if !ok {
+		t.Fatal("could not find MPHF")
+	}
+	if got := *m.fnv.positions; !reflect.DeepEqual(got, []int{4, 6}) {
+		t.Errorf("got positions %v, expected [4 6]", got)
+	}
+	if hasCollisions(cases, m.fnv) {
+		t.Errorf("hash collision in %v", cases)
+	}
+	global, ok := findMPHF(append([]string(nil), cases...))
+	if !ok {
+		t.Fatal("could not find MPHF")
+	}
+	if s, g := avgBytesHashed(m.fnv, cases), avgBytesHashed(global.fnv, cases); s >= g {
+		t.Errorf("got %.1f bytes hashed per key, expected less than %.1f", s, g)
+	}
+	for _, str := range cases {
+		if _, ok := m.Lookup(str); !ok {
+			t.Errorf("Lookup(%q) not found", str)
+		}
+	}
+
+	// A key that is a prefix of another key with the same length byte
+	long := "x" + string(make([]byte, 256))
+	m, ok = findMPHFOptions([]string{"x", long, "y"}, Options{SkipConstantBytes: true})
+	if !ok {
+		t.Fatal("could not find MPHF")
+	}
+	for _, str := range []string{"x", long, "y"} {
+		if _, ok := m.Lookup(str); !ok {
+			t.Errorf("Lookup(%q) not found", str)
+		}
+	}
+
+	for _, cases := range testcases {
+		m, ok := findMPHFOptions(cases, Options{SkipConstantBytes: true})
+		if !ok {
+			t.Fatal("could not find MPHF")
+		}
+		for _, str := range cases {
+			if _, ok := m.Lookup(str); !ok {
+				t.Errorf("Lookup(%q) not found", str)
+			}
+		}
+	}
+}
+
+func TestCountMPHFsSeed(t *testing.T) {
+	// Sets which fail now and then with a single attempt per strlen
+	var sets [][]string
+	for n := 28; n < 64; n++ {
+		cases := make([]string, n)
+		for i := range cases {
+			cases[i] = fmt.Sprintf("key%02d", i)
+		}
+		sets = append(sets, cases)
+	}
+
+	run := func() [3]int {
+		opts := Options{Rand: rand.New(rand.NewSource(42)), Attempts: 1}
+		s, m, n := countMPHFs(sets, opts)
+		return [3]int{s, m, n}
+	}
+	first, second := run(), run()
+	if first != second {
+		t.Errorf("got counts %v and %v with the same seed", first, second)
+	}
+	if first[2] != len(sets) {
+		t.Errorf("got total %d, expected %d", first[2], len(sets))
+	}
+}
+
+func TestFindMPHFAll(t *testing.T) {
+	// 255 keys in 256 jump table slots leave too little room for the buckets
+	hard := make([]string, 255)
+	for i := range hard {
+		hard[i] = fmt.Sprintf("key%03d", i)
+	}
+	sets := [][]string{
+		{"386", "amd64", "arm"},
+		hard,
+		{"a", "bb", "ccc"},
+	}
+
+	results, failed := FindMPHFAll(sets)
+	if !reflect.DeepEqual(failed, []int{1}) {
+		t.Errorf("got failed sets %v, expected [1]", failed)
+	}
+	if len(results) != len(sets) {
+		t.Fatalf("got %d results, expected %d", len(results), len(sets))
+	}
+	for i, m := range results {
+		if (m == nil) != (i == 1) {
+			t.Errorf("got result %v for set %d", m, i)
+		}
+	}
+	for _, key := range sets[0] {
+		if _, ok := results[0].Lookup(key); !ok {
+			t.Errorf("key %q not found", key)
+		}
+	}
+}
+
+func TestLookupAll(t *testing.T) {
+	for _, cases := range testcases {
+		m, ok := findMPHF(cases)
+		if !ok {
+			t.Fatal("could not find MPHF")
+		}
+
+		keys := append([]string{"unknown", ""}, cases...)
+		out := make([]int, len(keys))
+		m.LookupAll(keys, out)
+		for i, key := range keys {
+			ix, ok := m.Lookup(key)
+			if !ok {
+				ix = -1
+			}
+			if out[i] != ix {
+				t.Errorf("LookupAll gave %d for %q, Lookup gave %d", out[i], key, ix)
+			}
 		}
+	}
+}
 
-		got = bits.OnesCount32(m.jmpMask)
-		if got != expected {
-			t.Errorf("got mask with %d one-bits, expected %d", got, expected)
+// hashStringLoop is the reference implementation of fnv1a.hashString, with
+// both bounds checked in each iteration.
+func hashStringLoop(f fnv1a, input string) uint32 {
+	sum := f.hashByte(f.offset, byte(len(input)))
+	for i := 0; i < len(input) && i < f.strlen; i++ {
+		sum = f.hashByte(sum, input[i])
+	}
+	return sum
+}
+
+func TestHashStringEquivalence(t *testing.T) {
+	inputs := []string{"", "a", "ab", "abc", "amd64", "TrimSuffix", string(make([]byte, 300))}
+	for _, strlen := range []int{0, 1, 2, 3, 5, 8, 1 << 30} {
+		f := newFnv1a(rand.Uint32(), strlen)
+		for _, str := range inputs {
+			if got, expected := f.hashString(str), hashStringLoop(f, str); got != expected {
+				t.Errorf("strlen %d: got hash %#x for %q, expected %#x", strlen, got, str, expected)
+			}
 		}
+	}
+}
 
-		if len(m.jmpTab) <= len(cases) {
-			t.Errorf("jump table must have more entries than the cases it codes")
+func BenchmarkHashString(b *testing.B) {
+	hashes := make([]fnv1a, len(testcases))
+	for i, cases := range testcases {
+		fnv, _, ok := findHash(cases)
+		if !ok {
+			b.Error("could not find hash")
 		}
-		if len(m.jmpTab) != int(m.jmpMask+1) {
-			t.Errorf("got jump table size %d, expected %d", len(m.jmpTab), m.jmpMask+1)
+		hashes[i] = fnv
+	}
+
+	var x, y int
+	b.Run("hashString", func(b *testing.B) {
+		for k := 0; k < b.N; k++ {
+			y++
+			if y >= len(testcases[x]) {
+				x = (x + 1) % len(testcases)
+				y = 0
+			}
+
+			hashes[x].hashString(testcases[x][y])
 		}
+	})
 
-		hasHash := make([]bool, len(m.jmpTab))
-		for _, str := range cases {
-			hash := m.hashString(str)
-			if hash >= uint32(len(m.jmpTab)) {
-				t.Errorf("hash(%q)=%d exceeds jump table %d", str, hash, len(m.jmpTab))
-				continue
+	x, y = 0, 0
+	b.Run("loop", func(b *testing.B) {
+		for k := 0; k < b.N; k++ {
+			y++
+			if y >= len(testcases[x]) {
+				x = (x + 1) % len(testcases)
+				y = 0
 			}
-			if hasHash[hash] {
-				t.Errorf("hash collision for %q in %+v", str, cases)
+
+			hashStringLoop(hashes[x], testcases[x][y])
+		}
+	})
+}
+
+func BenchmarkPerLengthStrlen(b *testing.B) {
+	for _, bc := range []struct {
+		name string
+		opts Options
+	}{
+		{"global", Options{}},
+		{"per-length", Options{PerLengthStrlen: true}},
+	} {
+		hashes := make([]fnv1a, len(testcases))
+		bytes := 0.0
+		for i, cases := range testcases {
+			m, ok := findMPHFOptions(cases, bc.opts)
+			if !ok {
+				b.Error("could not find MPHF")
 			}
-			hasHash[hash] = true
+			hashes[i] = m.fnv
+			bytes += avgBytesHashed(m.fnv, cases)
 		}
+
+		var x, y int
+		b.Run(bc.name, func(b *testing.B) {
+			for k := 0; k < b.N; k++ {
+				y++
+				if y >= len(testcases[x]) {
+					x = (x + 1) % len(testcases)
+					y = 0
+				}
+
+				hashes[x].hashString(testcases[x][y])
+			}
+			b.ReportMetric(bytes/float64(len(testcases)), "bytes/key")
+		})
 	}
 }
 
@@ -79,6 +1347,67 @@ func BenchmarkFindHash(b *testing.B) {
 	})
 }
 
+func BenchmarkFindMPHFFast(b *testing.B) {
+	var hard []string
+	for i := 0; i < 250; i++ {
+		hard = append(hard, fmt.Sprintf("key%06d", i*7919))
+	}
+	for _, bc := range []struct {
+		name string
+		find func([]string) (*mphf, bool)
+	}{
+		{"findMPHF", findMPHF},
+		{"FindMPHFFast", func(cases []string) (*mphf, bool) { return FindMPHFFast(cases, Options{}) }},
+	} {
+		b.Run(bc.name+"/easy", func(b *testing.B) {
+			found := 0
+			for i := 0; i < b.N; i++ {
+				if _, ok := bc.find(testcases[i%len(testcases)]); ok {
+					found++
+				}
+			}
+			b.ReportMetric(float64(found)/float64(b.N), "success")
+		})
+		b.Run(bc.name+"/hard", func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				bc.find(hard)
+			}
+		})
+	}
+}
+
+func BenchmarkNewMPHF(b *testing.B) {
+	var keys []string
+	for _, cases := range testcases {
+		keys = append(keys, cases...)
+	}
+	keys = Deduplicate(keys)
+	fnv, _, sums, ok := findSumsWith(keys, hashTemplate(keys, MinInputLen(keys), Options{}), Options{})
+	if !ok {
+		b.Fatal("could not find hash")
+	}
+	keyBytes := 0
+	for _, key := range keys {
+		keyBytes += fnv.inputLen(key)
+	}
+
+	// newMPHF hashes the keys once for the buckets. Before newMPHFSums, it
+	// hashed them once more to fill the jump table, after findHash had
+	// already hashed them for the collision check.
+	b.Run("rehash", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			newMPHF(keys, fnv, Options{})
+		}
+		b.ReportMetric(float64(keyBytes), "hashed-B/op")
+	})
+	b.Run("sums", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			newMPHFSums(keys, sums, fnv, Options{})
+		}
+		b.ReportMetric(0, "hashed-B/op")
+	})
+}
+
 func BenchmarkJumpTables(b *testing.B) {
 	hashes := make([]*mphf, len(testcases))
 	for i, cases := range testcases {
@@ -127,7 +1456,7 @@ func BenchmarkJumpTables(b *testing.B) {
 func BenchmarkHashes(b *testing.B) {
 	hashes := make([]fnv1a, len(testcases))
 	for i, cases := range testcases {
-		fnv, ok := findHash(cases)
+		fnv, _, ok := findHash(cases)
 		if !ok {
 			b.Error("could not find MPHF")
 		}
@@ -191,3 +1520,251 @@ func BenchmarkHashes(b *testing.B) {
 		}
 	})
 }
+
+func BenchmarkInitBuckets(b *testing.B) {
+	// Find the hash sums up front, so that only the shift search is measured
+	opts := Options{Rand: rand.New(rand.NewSource(1))}
+	tables := make([]mphf, len(testcases))
+	sums := make([][]uint32, len(testcases))
+	for i, cases := range testcases {
+		cases = Deduplicate(append([]string(nil), cases...))
+		fnv, _, s, ok := findSumsWith(cases, hashTemplate(cases, MinInputLen(cases), opts), opts)
+		if !ok {
+			b.Fatal("could not find hash")
+		}
+		tables[i].fnv = fnv
+		tables[i].initTables(len(cases), opts)
+		sums[i] = s
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		x := i % len(testcases)
+		tables[x].initBuckets(sums[x], nil, nil, false)
+	}
+}
+
+func BenchmarkInitBucketsBalanced(b *testing.B) {
+	// Each bucket gets 3 hash sums, so sorting by size changes nothing
+	const n = 3 * 1024
+	r := rand.New(rand.NewSource(1))
+	var m mphf
+	m.initTables(n, Options{})
+	sums := make([]uint32, n)
+	for i := range sums {
+		sums[i] = r.Uint32()&^m.bktMask | uint32(i)&m.bktMask
+	}
+
+	for _, unsorted := range []bool{false, true} {
+		name := "sorted"
+		if unsorted {
+			name = "unsorted"
+		}
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				m.initBuckets(sums, nil, nil, unsorted)
+			}
+		})
+	}
+}
+
+func TestUnsortedBuckets(t *testing.T) {
+	for _, cases := range testcases {
+		m, ok := findMPHFOptions(cases, Options{UnsortedBuckets: true})
+		if !ok {
+			t.Fatal("could not find MPHF")
+		}
+		for _, str := range cases {
+			if _, ok := m.Lookup(str); !ok {
+				t.Errorf("%q not found", str)
+			}
+		}
+	}
+}
+
+// growRetry hashes one more byte in each attempt, up to max attempts
+type growRetry struct {
+	max     int
+	strlens []int
+	errs    []error
+}
+
+func (r *growRetry) Next(attempt int, lastErr error) (bool, int) {
+	r.errs = append(r.errs, lastErr)
+	if attempt >= r.max {
+		return false, 0
+	}
+	r.strlens = append(r.strlens, attempt)
+	return true, attempt
+}
+
+func TestRetryStrategy(t *testing.T) {
+	cases := []string{"TrimPrefix", "TrimSuffix", "TrimString", "TrimLeft", "TrimRight"}
+	retry := &growRetry{max: 20}
+	m, _, attempts, ok := findMPHFCount(append([]string(nil), cases...), Options{Retry: retry, Rand: rand.New(rand.NewSource(1))})
+	if !ok {
+		t.Fatal("could not find MPHF")
+	}
+	// The keys with the same length differ at offset 5
+	if m.fnv.strlen < 6 {
+		t.Errorf("got strlen %d, expected at least 6", m.fnv.strlen)
+	}
+	if attempts != len(retry.strlens) || m.fnv.strlen != retry.strlens[len(retry.strlens)-1] {
+		t.Errorf("got %d attempts with strlens %v, expected the last strlen %d", attempts, retry.strlens, m.fnv.strlen)
+	}
+	if retry.errs[0] != nil || retry.errs[1] != errSumCollision {
+		t.Errorf("got errors %v, expected nil and then errSumCollision", retry.errs)
+	}
+	for _, str := range cases {
+		if _, ok := m.Lookup(str); !ok {
+			t.Errorf("%q not found", str)
+		}
+	}
+
+	if _, ok := findMPHFOptions(append([]string(nil), cases...), Options{Retry: &growRetry{max: 5}}); ok {
+		t.Error("found MPHF with fewer than 6 bytes of the keys")
+	}
+}
+
+func TestRequireUTF8(t *testing.T) {
+	cases := []string{"abc", "åäö", "bad\xff", "日本"}
+	_, ok, err := FindMPHFOptions(cases, Options{RequireUTF8: true})
+	if ok || err == nil {
+		t.Fatalf("got ok %v, err %v, expected an error", ok, err)
+	}
+	if !strings.Contains(err.Error(), `"bad\xff"`) || strings.Contains(err.Error(), "abc") {
+		t.Errorf("error %q does not list exactly the invalid key", err)
+	}
+
+	m, ok, err := FindMPHFOptions(cases, Options{})
+	if !ok || err != nil {
+		t.Fatalf("got ok %v, err %v, expected a MPHF without RequireUTF8", ok, err)
+	}
+	if _, ok := m.Lookup("bad\xff"); !ok {
+		t.Error(`"bad\xff" not found`)
+	}
+}
+
+func TestMaxTableSize(t *testing.T) {
+	// 100 keys need a jump table of 128 slots
+	var cases []string
+	for i := 0; i < 100; i++ {
+		cases = append(cases, fmt.Sprintf("key%d", i))
+	}
+	if _, ok := findMPHFOptions(append([]string(nil), cases...), Options{MaxTableSize: 100}); ok {
+		t.Error("found MPHF with a jump table larger than MaxTableSize")
+	}
+	if _, ok := newMPHF(cases, fnv1a{strlen: 5}, Options{MaxTableSize: 64}); ok {
+		t.Error("newMPHF built a jump table larger than MaxTableSize")
+	}
+
+	m, ok := findMPHFOptions(append([]string(nil), cases...), Options{MaxTableSize: 128})
+	if !ok {
+		t.Fatal("could not find MPHF")
+	}
+	if len(m.jmpTab) != 128 {
+		t.Errorf("got %d slots, expected 128", len(m.jmpTab))
+	}
+}
+
+func TestDenseIndex(t *testing.T) {
+	for _, cases := range testcases {
+		m, ok := findMPHF(cases)
+		if !ok {
+			t.Fatal("could not find MPHF")
+		}
+		n := len(m.SortedEntries())
+		seen := make([]bool, n)
+		for _, str := range cases {
+			ix, ok := m.DenseIndex(str)
+			if !ok || ix < 0 || ix >= n {
+				t.Fatalf("DenseIndex(%q) = %d, %v; expected an index in [0, %d)", str, ix, ok, n)
+			}
+			seen[ix] = true
+		}
+		for ix, ok := range seen {
+			if !ok {
+				t.Errorf("no key has dense index %d", ix)
+			}
+		}
+		if ix, ok := m.DenseIndex("\xffunknown"); ok || ix != -1 {
+			t.Errorf("DenseIndex of an unknown key = %d, %v; expected -1, false", ix, ok)
+		}
+	}
+
+	// Remove shifts the ranks of the later keys
+	m, ok := findMPHF([]string{"a", "bb", "ccc", "dddd"})
+	if !ok {
+		t.Fatal("could not find MPHF")
+	}
+	first := m.SortedEntries()[0].Key
+	m.Remove(first)
+	for i, e := range m.SortedEntries() {
+		if ix, ok := m.DenseIndex(e.Key); !ok || ix != i {
+			t.Errorf("DenseIndex(%q) = %d, %v after Remove; expected %d", e.Key, ix, ok, i)
+		}
+	}
+}
+
+func TestFindMPHFProgress(t *testing.T) {
+	for _, cases := range testcases {
+		var calls []int
+		m, ok := FindMPHFProgress(append([]string(nil), cases...), func(attempt int) {
+			calls = append(calls, attempt)
+		})
+		if !ok {
+			t.Fatal("could not find MPHF")
+		}
+		if len(calls) == 0 {
+			t.Fatal("progress was not called")
+		}
+		for i, attempt := range calls {
+			if attempt != i+1 {
+				t.Fatalf("got attempts %v, expected 1, 2, 3, ...", calls)
+			}
+		}
+		for _, str := range cases {
+			if _, ok := m.Lookup(str); !ok {
+				t.Errorf("%q not found", str)
+			}
+		}
+	}
+
+	// Every attempt fails with a full jump table
+	var calls int
+	if _, ok := findMPHFOptions([]string{"a", "b"}, Options{FixedStrLen: 1, Attempts: 5, ReservedSlots: []int{0, 1, 2, 3}, progress: func(int) { calls++ }}); ok {
+		t.Fatal("found MPHF with all slots reserved")
+	}
+	if calls != 5 {
+		t.Errorf("progress was called %d times, expected 5", calls)
+	}
+}
+
+func TestRun(t *testing.T) {
+	sets := [][]string{
+		{"a", "bb", "ccc"},
+		{"386", "amd64", "arm", "arm64", "ppc64", "wasm"},
+		{"TrimPrefix", "TrimSuffix", "TrimString", "Trim", "Tree"},
+		{},
+	}
+	cfg := Config{Sets: sets, Rand: rand.New(rand.NewSource(1))}
+	r := run(cfg)
+	if r.Success != 4 || r.MPHFs != 4 || r.Total != 4 {
+		t.Errorf("got %d successes, %d MPHFs of %d sets, expected 4 of 4", r.Success, r.MPHFs, r.Total)
+	}
+	if r.SuccessRate != 100 || r.MPHFRate != 100 {
+		t.Errorf("got rates %.1f%% and %.1f%%, expected 100%%", r.SuccessRate, r.MPHFRate)
+	}
+
+	// The same seed gives the same report
+	cfg.Rand = rand.New(rand.NewSource(1))
+	again := run(cfg)
+	again.Duration = r.Duration
+	if again != r {
+		t.Errorf("got %+v and %+v with the same seed", r, again)
+	}
+
+	if r := run(Config{Rand: rand.New(rand.NewSource(1))}); r.Total != 0 || r.SuccessRate != 0 {
+		t.Errorf("got %+v for no sets", r)
+	}
+}
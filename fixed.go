@@ -0,0 +1,25 @@
+package main
+
+import "bytes"
+
+// hashFixed hashes the 4 bytes of key. All keys have the same length, so
+// there is no length byte and strlen is not used.
+func (f fnv1a) hashFixed(key [4]byte) uint32 {
+	sum := f.offset
+	for _, b := range key {
+		sum = f.hashByte(sum, b)
+	}
+	return sum
+}
+
+// mphfFixed is a (near) minimal perfect hash function for 4-byte keys, such
+// as protocol tags, on the hash of the key bytes.
+type mphfFixed = mphfKeyed[[4]byte]
+
+// FindMPHFFixed tries seeds until it finds a near minimal perfect hash
+// function for keys.
+// Returns true if found, false if no success after maxAttempts iterations.
+func FindMPHFFixed(keys [][4]byte) (*mphfFixed, bool) {
+	keys = distinctKeys(keys, func(a, b [4]byte) int { return bytes.Compare(a[:], b[:]) })
+	return findMPHFKeyed(keys, 0, fnv1a.hashFixed)
+}
@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+func TestFindMPHFFixed(t *testing.T) {
+	// Four-character codes, as in RIFF and TrueType tables
+	var keys [][4]byte
+	for _, tag := range []string{"RIFF", "WAVE", "fmt ", "data", "LIST", "cmap", "glyf", "head", "hhea", "hmtx", "loca", "maxp", "name", "post", "data"} {
+		var key [4]byte
+		copy(key[:], tag)
+		keys = append(keys, key)
+	}
+	m, ok := FindMPHFFixed(keys)
+	if !ok {
+		t.Fatal("could not find MPHF")
+	}
+
+	seen := make(map[int][4]byte)
+	for _, key := range keys {
+		ix, ok := m.Lookup(key)
+		if !ok {
+			t.Errorf("Lookup(%q) not found", key[:])
+			continue
+		}
+		if other, exists := seen[ix]; exists && other != key {
+			t.Errorf("%q and %q map to the same index %d", key[:], other[:], ix)
+		}
+		seen[ix] = key
+	}
+	if len(seen) != len(keys)-1 {
+		t.Errorf("got %d distinct indices, expected %d", len(seen), len(keys)-1)
+	}
+
+	for _, key := range [][4]byte{{}, {'R', 'I', 'F', 'X'}, {'d', 'a', 't', 0}} {
+		if ix, ok := m.Lookup(key); ok {
+			t.Errorf("Lookup(%q) = %d, expected not found", key[:], ix)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/jupj/go-issue-34381/testdata/lookup"
+)
+
+// The generated lookup for the keys of testdata/lookup/keys.txt is checked in
+// as package testdata/lookup, so that it can be benchmarked against the
+// runtime mphf. To regenerate it, and to run the benchmarks:
+//
+//	go generate
+//	go test -run TestGeneratedLookup -bench Lookup
+
+//go:generate go run . -keys testdata/lookup/keys.txt -func Lookup -pkg lookup -o testdata/lookup/lookup.go
+
+// generatedKeys returns the keys of testdata/lookup, and writes their
+// generated lookup to w
+func generatedKeys(tb testing.TB, w io.Writer) ([]string, *mphf) {
+	f, err := os.Open("testdata/lookup/keys.txt")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer f.Close()
+	keys, err := ReadKeys(f)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	m, err := GenerateFile(w, "lookup", "Lookup", keys)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return keys, m
+}
+
+func TestGeneratedLookup(t *testing.T) {
+	var buf bytes.Buffer
+	keys, m := generatedKeys(t, &buf)
+	src, err := os.ReadFile("testdata/lookup/lookup.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(src, buf.Bytes()) {
+		t.Fatal("testdata/lookup/lookup.go is out of date, run go generate")
+	}
+
+	for _, key := range append([]string{"not a key", "int128", ""}, keys...) {
+		expected, ok := m.Lookup(key)
+		if !ok {
+			expected = -1
+		}
+		if got := lookup.Lookup(key); got != expected {
+			t.Errorf("lookup.Lookup(%q) = %d, expected %d", key, got, expected)
+		}
+	}
+}
+
+func BenchmarkLookup(b *testing.B) {
+	keys, m := generatedKeys(b, io.Discard)
+
+	var x, y int
+	b.Run("generated", func(b *testing.B) {
+		for k := 0; k < b.N; k++ {
+			y += lookup.Lookup(keys[x])
+			x = (x + 1) % len(keys)
+		}
+	})
+	b.Run("mphf", func(b *testing.B) {
+		for k := 0; k < b.N; k++ {
+			ix, _ := m.Lookup(keys[x])
+			y += ix
+			x = (x + 1) % len(keys)
+		}
+	})
+}
@@ -1,3 +1,3 @@
 module github.com/jupj/go-issue-34381
 
-go 1.15
+go 1.23
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"go/scanner"
+	"go/token"
+)
+
+// FindMPHFFromGoIdents finds a MPHF for the identifiers in the Go source src.
+// Keywords, literals and comments are not identifiers.
+// Returns false if no MPHF is found, and an error if src cannot be scanned.
+func FindMPHFFromGoIdents(src []byte) (*mphf, bool, error) {
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+	var errs scanner.ErrorList
+	var s scanner.Scanner
+	s.Init(file, src, errs.Add, 0)
+
+	var idents []string
+	for {
+		_, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if tok == token.IDENT {
+			idents = append(idents, lit)
+		}
+	}
+	if err := errs.Err(); err != nil {
+		return nil, false, err
+	}
+
+	m, ok := findMPHF(idents)
+	return m, ok, nil
+}
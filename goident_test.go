@@ -0,0 +1,47 @@
+package main
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestFindMPHFFromGoIdents(t *testing.T) {
+	const src = `package lexer
+
+// Token is a "token" of the input
+func Next(input string) (tok Token, rest string) {
+	if len(input) == 0 {
+		return EOF, input
+	}
+	return Token(input[0]), input[1:]
+}
+`
+	m, ok, err := FindMPHFFromGoIdents([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("could not find MPHF")
+	}
+
+	expected := []string{"EOF", "Next", "Token", "input", "len", "lexer", "rest", "string", "tok"}
+	keys := m.keys()
+	sort.Strings(keys)
+	if len(keys) != len(expected) {
+		t.Errorf("got identifiers %q, expected %q", keys, expected)
+	}
+	for _, ident := range expected {
+		if _, ok := m.Lookup(ident); !ok {
+			t.Errorf("identifier %q not found", ident)
+		}
+	}
+	for _, notIdent := range []string{"func", "return", "token", "0"} {
+		if _, ok := m.Lookup(notIdent); ok {
+			t.Errorf("found %q, which is not an identifier", notIdent)
+		}
+	}
+
+	if _, _, err := FindMPHFFromGoIdents([]byte("x := \"unterminated\n")); err == nil {
+		t.Error("expected an error for invalid source")
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// FindMPHFFromLengthPrefixed reads keys from r and finds a MPHF for them. Each
+// key is a 4-byte big endian length followed by that many bytes, so keys may
+// contain any bytes. Reading stops at EOF.
+// Returns an error if reading fails or r ends within a key, and false if no
+// MPHF was found. Keys are read as they arrive, so a length beyond the end of
+// r does not allocate memory for the missing bytes.
+func FindMPHFFromLengthPrefixed(r io.Reader) (*mphf, bool, error) {
+	var cases []string
+	var hdr [4]byte
+	for {
+		_, err := io.ReadFull(r, hdr[:])
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, false, err
+		}
+
+		n := int64(binary.BigEndian.Uint32(hdr[:]))
+		var key bytes.Buffer
+		if read, err := io.CopyN(&key, r, n); err != nil {
+			if err == io.EOF {
+				err = fmt.Errorf("mphf: key %d truncated after %d of %d bytes: %w", len(cases), read, n, io.ErrUnexpectedEOF)
+			}
+			return nil, false, err
+		}
+		cases = append(cases, key.String())
+	}
+
+	m, ok := findMPHF(cases)
+	return m, ok, nil
+}
+
+// ReadKeys reads keys from r, one per line, without the line ending "\n" or
+// "\r\n". Empty lines are skipped, so keys cannot be empty or contain line
+// breaks; see FindMPHFFromLengthPrefixed for arbitrary keys.
+func ReadKeys(r io.Reader) ([]string, error) {
+	var keys []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if key := scanner.Text(); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys, scanner.Err()
+}
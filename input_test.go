@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeLengthPrefixed writes keys in the format of FindMPHFFromLengthPrefixed
+func writeLengthPrefixed(w io.Writer, keys []string) {
+	for _, key := range keys {
+		var hdr [4]byte
+		binary.BigEndian.PutUint32(hdr[:], uint32(len(key)))
+		w.Write(hdr[:])
+		io.WriteString(w, key)
+	}
+}
+
+func TestFindMPHFFromLengthPrefixed(t *testing.T) {
+	keys := []string{"line\nbreak", "nul\x00byte", "\n", "\x00", "", "plain", "two\nlines\n"}
+	var buf bytes.Buffer
+	writeLengthPrefixed(&buf, keys)
+	data := buf.Bytes()
+
+	m, ok, err := FindMPHFFromLengthPrefixed(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("could not find MPHF")
+	}
+	for _, key := range keys {
+		if _, ok := m.Lookup(key); !ok {
+			t.Errorf("Lookup(%q) not found", key)
+		}
+	}
+	if _, ok := m.Lookup("line"); ok {
+		t.Error("Lookup found a part of a key")
+	}
+
+	// A length far beyond the end of the data
+	if _, _, err := FindMPHFFromLengthPrefixed(bytes.NewReader([]byte{0x40, 0, 0, 0, 'x'})); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("got error %v for a 1 GiB key of 1 byte, expected %v", err, io.ErrUnexpectedEOF)
+	}
+
+	// Truncated key and truncated length
+	for _, n := range []int{len(data) - 1, len(data) - len("two\nlines\n") - 2} {
+		_, _, err := FindMPHFFromLengthPrefixed(bytes.NewReader(data[:n]))
+		if !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("got error %v for %d bytes, expected %v", err, n, io.ErrUnexpectedEOF)
+		}
+	}
+}
+
+func TestReadKeys(t *testing.T) {
+	keys, err := ReadKeys(strings.NewReader("386\namd64\n\narm\r\nwasm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"386", "amd64", "arm", "wasm"}; !reflect.DeepEqual(keys, expected) {
+		t.Errorf("got keys %q, expected %q", keys, expected)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import "slices"
+
+// mphfKeyed is a (near) minimal perfect hash function for keys of a comparable
+// type other than string. It uses the same buckets and shifts as mphf, on the
+// hash sums of hash.
+type mphfKeyed[K comparable] struct {
+	h      mphf // hash parameters, h.jmpTab is not used
+	hash   func(f fnv1a, key K) uint32
+	jmpTab []keyedEntry[K]
+}
+
+type keyedEntry[K comparable] struct {
+	key   K
+	valid bool
+}
+
+// distinctKeys returns a sorted copy of keys without duplicates
+func distinctKeys[K comparable](keys []K, cmp func(a, b K) int) []K {
+	keys = slices.Clone(keys)
+	slices.SortFunc(keys, cmp)
+	return slices.Compact(keys)
+}
+
+// findMPHFKeyed tries seeds until it finds a near minimal perfect hash
+// function for the distinct keys, with hash sums from hash and fnv1a of
+// strlen.
+// Returns true if found, false if no success after maxAttempts iterations.
+func findMPHFKeyed[K comparable](keys []K, strlen int, hash func(fnv1a, K) uint32) (*mphfKeyed[K], bool) {
+	var opts Options
+	sums := make([]uint32, len(keys))
+	hashes := make(map[uint32]struct{}, len(keys))
+	for i := 0; i < opts.attempts(); i++ {
+		fnv := newFnv1a(opts.seed(), strlen)
+		clear(hashes)
+		for j, key := range keys {
+			sums[j] = hash(fnv, key)
+			hashes[sums[j]] = struct{}{}
+		}
+		if len(hashes) != len(keys) {
+			continue
+		}
+
+		m := &mphfKeyed[K]{hash: hash}
+		m.h.fnv = fnv
+		m.h.initTables(len(keys), opts)
+		if !m.h.initBuckets(sums, nil, nil, false) {
+			continue
+		}
+		m.jmpTab = make([]keyedEntry[K], m.h.jmpMask+1)
+		for _, key := range keys {
+			m.jmpTab[m.jmpIndex(key)] = keyedEntry[K]{key, true}
+		}
+		return m, true
+	}
+	return nil, false
+}
+
+// jmpIndex calculates the jump table index of key
+func (m *mphfKeyed[K]) jmpIndex(key K) uint32 {
+	sum := m.hash(m.h.fnv, key)
+	return m.h.jmpIx(sum, m.h.shiftAt(sum&m.h.bktMask))
+}
+
+// Lookup returns the jump table index of key. Returns false if key is not in
+// the set.
+func (m *mphfKeyed[K]) Lookup(key K) (int, bool) {
+	ix := m.jmpIndex(key)
+	if e := m.jmpTab[ix]; !e.valid || e.key != key {
+		return -1, false
+	}
+	return int(ix), true
+}
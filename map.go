@@ -0,0 +1,372 @@
+package main
+
+import (
+	"encoding"
+	"encoding/binary"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+)
+
+// Map is a MPHF over string keys with a value for each key.
+type Map[V any] struct {
+	m      *mphf
+	values []V // indexed by jump table index
+}
+
+// Get returns the value of key, or false if key is not in the map.
+func (mp *Map[V]) Get(key string) (V, bool) {
+	ix, ok := mp.m.Lookup(key)
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	return mp.values[ix], true
+}
+
+// Set replaces the value of key with v. It returns false, and leaves the map
+// unchanged, if key is not in the map: adding a key requires a new MPHF.
+func (mp *Map[V]) Set(key string, v V) bool {
+	ix, ok := mp.m.Lookup(key)
+	if !ok {
+		return false
+	}
+	mp.values[ix] = v
+	return true
+}
+
+// Len returns the number of keys in the map
+func (mp *Map[V]) Len() int {
+	return len(mp.m.keys())
+}
+
+// findMap finds a MPHF for keys and stores values[i] for keys[i]. If a key
+// occurs more than once, the last value wins.
+func findMap[V any](keys []string, values []V) (*Map[V], bool) {
+	m, ok := findMPHF(append([]string(nil), keys...))
+	if !ok {
+		return nil, false
+	}
+	mp := &Map[V]{m: m, values: make([]V, len(m.jmpTab))}
+	for i, key := range keys {
+		ix, _ := m.Lookup(key)
+		mp.values[ix] = values[i]
+	}
+	return mp, true
+}
+
+// LazyMap is a Map that computes the value of a key on its first Get.
+type LazyMap[V any] struct {
+	m      *mphf
+	valFn  func(key string) V
+	once   []sync.Once // indexed by jump table index
+	values []V
+}
+
+// NewLazyMap finds a MPHF for keys. The value of a key is valFn(key), which is
+// called at most once per key, when the key is first looked up.
+// Returns false if no MPHF is found.
+func NewLazyMap[V any](keys []string, valFn func(key string) V) (*LazyMap[V], bool) {
+	m, ok := findMPHF(append([]string(nil), keys...))
+	if !ok {
+		return nil, false
+	}
+	return &LazyMap[V]{
+		m:      m,
+		valFn:  valFn,
+		once:   make([]sync.Once, len(m.jmpTab)),
+		values: make([]V, len(m.jmpTab)),
+	}, true
+}
+
+// Get returns the value of key, or false if key is not in the map.
+func (mp *LazyMap[V]) Get(key string) (V, bool) {
+	ix, ok := mp.m.Lookup(key)
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	mp.once[ix].Do(func() {
+		mp.values[ix] = mp.valFn(mp.m.jmpTab[ix].key)
+	})
+	return mp.values[ix], true
+}
+
+// FindMapFromCSV reads CSV records from r and builds a Map from column keyCol
+// to column valCol. All records are data, there is no header. If a key occurs
+// in more than one record, the last record wins.
+// Returns false if no MPHF is found, and an error if r is not valid CSV.
+func FindMapFromCSV(r io.Reader, keyCol, valCol int) (*Map[string], bool, error) {
+	return findMapFromCSV(r, keyCol, valCol, false)
+}
+
+// FindMapFromCSVStrict is FindMapFromCSV, but returns an error if a key occurs
+// in more than one record.
+func FindMapFromCSVStrict(r io.Reader, keyCol, valCol int) (*Map[string], bool, error) {
+	return findMapFromCSV(r, keyCol, valCol, true)
+}
+
+func findMapFromCSV(r io.Reader, keyCol, valCol int, strict bool) (*Map[string], bool, error) {
+	if keyCol < 0 || valCol < 0 {
+		return nil, false, fmt.Errorf("csv: invalid columns %d, %d", keyCol, valCol)
+	}
+	cols := keyCol + 1
+	if valCol >= keyCol {
+		cols = valCol + 1
+	}
+
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	var keys, values []string
+	line := make(map[string]int)
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		row, _ := cr.FieldPos(0)
+		if len(record) < cols {
+			return nil, false, fmt.Errorf("csv: line %d has %d columns, need %d", row, len(record), cols)
+		}
+		key := record[keyCol]
+		if prev, dup := line[key]; dup && strict {
+			return nil, false, fmt.Errorf("csv: line %d: duplicate key %q from line %d", row, key, prev)
+		}
+		line[key] = row
+		keys = append(keys, key)
+		values = append(values, record[valCol])
+	}
+
+	mp, ok := findMap(keys, values)
+	return mp, ok, nil
+}
+
+// Field is a field name and number, as of a protobuf message. It is an alias
+// so that slices of the same anonymous struct can be passed to FindFieldMap.
+type Field = struct {
+	Name   string
+	Number int
+}
+
+// FindFieldMap builds a Map from the field names to the field numbers.
+// Returns false if no MPHF is found, and an error if a name occurs more than
+// once.
+func FindFieldMap(fields []Field) (*Map[int], bool, error) {
+	keys := make([]string, len(fields))
+	numbers := make([]int, len(fields))
+	seen := make(map[string]int)
+	for i, f := range fields {
+		if prev, dup := seen[f.Name]; dup {
+			return nil, false, fmt.Errorf("fields: duplicate name %q with numbers %d and %d", f.Name, prev, f.Number)
+		}
+		seen[f.Name] = f.Number
+		keys[i], numbers[i] = f.Name, f.Number
+	}
+
+	mp, ok := findMap(keys, numbers)
+	return mp, ok, nil
+}
+
+// MarshalBinary encodes the MPHF of the map, as mphf.MarshalBinary, and the
+// values of the keys in jump table order. V must be a bool, string, []byte,
+// an integer type, or implement encoding.BinaryMarshaler.
+//
+// The format is the length of the MPHF data as an unsigned varint, the MPHF
+// data, and a value per key: integers as varints, bools as a byte, and other
+// values as their length followed by their bytes.
+func (mp *Map[V]) MarshalBinary() ([]byte, error) {
+	data, err := mp.m.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	buf := binary.AppendUvarint(nil, uint64(len(data)))
+	buf = append(buf, data...)
+	for ix, e := range mp.m.jmpTab {
+		if !e.valid {
+			continue
+		}
+		if buf, err = appendValue(buf, mp.values[ix]); err != nil {
+			return nil, err
+		}
+	}
+	return buf, nil
+}
+
+// UnmarshalBinary decodes data written by MarshalBinary into mp.
+func (mp *Map[V]) UnmarshalBinary(data []byte) error {
+	n, size := binary.Uvarint(data)
+	if size <= 0 || n > uint64(len(data)-size) {
+		return errTruncated
+	}
+	var m mphf
+	if err := m.UnmarshalBinary(data[size : size+int(n)]); err != nil {
+		return err
+	}
+	data = data[size+int(n):]
+
+	values := make([]V, len(m.jmpTab))
+	for ix, e := range m.jmpTab {
+		if !e.valid {
+			continue
+		}
+		var err error
+		if data, err = readValue(data, &values[ix]); err != nil {
+			return err
+		}
+	}
+	if len(data) > 0 {
+		return errors.New("map: trailing data")
+	}
+	mp.m, mp.values = &m, values
+	return nil
+}
+
+// appendValue appends the encoding of v to buf
+func appendValue(buf []byte, v any) ([]byte, error) {
+	switch v := v.(type) {
+	case bool:
+		if v {
+			return append(buf, 1), nil
+		}
+		return append(buf, 0), nil
+	case int:
+		return binary.AppendVarint(buf, int64(v)), nil
+	case int8:
+		return binary.AppendVarint(buf, int64(v)), nil
+	case int16:
+		return binary.AppendVarint(buf, int64(v)), nil
+	case int32:
+		return binary.AppendVarint(buf, int64(v)), nil
+	case int64:
+		return binary.AppendVarint(buf, v), nil
+	case uint:
+		return binary.AppendUvarint(buf, uint64(v)), nil
+	case uint8:
+		return binary.AppendUvarint(buf, uint64(v)), nil
+	case uint16:
+		return binary.AppendUvarint(buf, uint64(v)), nil
+	case uint32:
+		return binary.AppendUvarint(buf, uint64(v)), nil
+	case uint64:
+		return binary.AppendUvarint(buf, v), nil
+	case string:
+		return append(binary.AppendUvarint(buf, uint64(len(v))), v...), nil
+	case []byte:
+		return append(binary.AppendUvarint(buf, uint64(len(v))), v...), nil
+	case encoding.BinaryMarshaler:
+		data, err := v.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		return append(binary.AppendUvarint(buf, uint64(len(data))), data...), nil
+	}
+	return nil, fmt.Errorf("map: cannot encode values of type %T", v)
+}
+
+// readValue decodes a value written by appendValue from data into v, and
+// returns the rest of data
+func readValue(data []byte, v any) ([]byte, error) {
+	varint := func() (int64, error) {
+		x, n := binary.Varint(data)
+		if n <= 0 {
+			return 0, errTruncated
+		}
+		data = data[n:]
+		return x, nil
+	}
+	uvarint := func() (uint64, error) {
+		x, n := binary.Uvarint(data)
+		if n <= 0 {
+			return 0, errTruncated
+		}
+		data = data[n:]
+		return x, nil
+	}
+	// sized and usized decode a varint that must fit in the given number
+	// of bits
+	sized := func(bits int) (int64, error) {
+		x, err := varint()
+		if err == nil && x != x<<(64-bits)>>(64-bits) {
+			err = fmt.Errorf("map: value %d overflows int%d", x, bits)
+		}
+		return x, err
+	}
+	usized := func(bits int) (uint64, error) {
+		u, err := uvarint()
+		if err == nil && u>>(bits-1)>>1 != 0 {
+			err = fmt.Errorf("map: value %d overflows uint%d", u, bits)
+		}
+		return u, err
+	}
+	bytes := func() ([]byte, error) {
+		n, err := uvarint()
+		if err != nil {
+			return nil, err
+		}
+		if n > uint64(len(data)) {
+			return nil, errTruncated
+		}
+		b := data[:n:n]
+		data = data[n:]
+		return b, nil
+	}
+
+	var err error
+	var x int64
+	var u uint64
+	var b []byte
+	switch v := v.(type) {
+	case *bool:
+		if len(data) == 0 {
+			return nil, errTruncated
+		}
+		*v, data = data[0] != 0, data[1:]
+	case *int:
+		x, err = sized(strconv.IntSize)
+		*v = int(x)
+	case *int8:
+		x, err = sized(8)
+		*v = int8(x)
+	case *int16:
+		x, err = sized(16)
+		*v = int16(x)
+	case *int32:
+		x, err = sized(32)
+		*v = int32(x)
+	case *int64:
+		*v, err = varint()
+	case *uint:
+		u, err = usized(strconv.IntSize)
+		*v = uint(u)
+	case *uint8:
+		u, err = usized(8)
+		*v = uint8(u)
+	case *uint16:
+		u, err = usized(16)
+		*v = uint16(u)
+	case *uint32:
+		u, err = usized(32)
+		*v = uint32(u)
+	case *uint64:
+		*v, err = uvarint()
+	case *string:
+		b, err = bytes()
+		*v = string(b)
+	case *[]byte:
+		b, err = bytes()
+		*v = append([]byte(nil), b...)
+	case encoding.BinaryUnmarshaler:
+		if b, err = bytes(); err == nil {
+			err = v.UnmarshalBinary(b)
+		}
+	default:
+		return nil, fmt.Errorf("map: cannot decode values of type %T", v)
+	}
+	return data, err
+}
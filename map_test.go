@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFindMapFromCSV(t *testing.T) {
+	const data = `amd64,x86-64,64
+arm,ARM,32
+"ppc64",PowerPC,64
+arm,ARMv7,32
+wasm,WebAssembly,32
+`
+	mp, ok, err := FindMapFromCSV(strings.NewReader(data), 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("could not find map")
+	}
+	if mp.Len() != 4 {
+		t.Errorf("got %d keys, expected 4", mp.Len())
+	}
+	for key, expected := range map[string]string{
+		"amd64": "x86-64",
+		"arm":   "ARMv7",
+		"ppc64": "PowerPC",
+		"wasm":  "WebAssembly",
+	} {
+		if v, ok := mp.Get(key); !ok || v != expected {
+			t.Errorf("got %q, %v for %q, expected %q", v, ok, key, expected)
+		}
+	}
+	if v, ok := mp.Get("386"); ok {
+		t.Errorf("got %q for unknown key", v)
+	}
+
+	_, _, err = FindMapFromCSVStrict(strings.NewReader(data), 0, 1)
+	if err == nil || !strings.Contains(err.Error(), `duplicate key "arm" from line 2`) {
+		t.Errorf("got error %v, expected duplicate key", err)
+	}
+
+	if _, _, err := FindMapFromCSV(strings.NewReader(data), 0, 3); err == nil {
+		t.Error("expected an error for a missing column")
+	}
+}
+
+func TestFindFieldMap(t *testing.T) {
+	// The fields of google.protobuf.FieldDescriptorProto
+	fields := []struct {
+		Name   string
+		Number int
+	}{
+		{"name", 1},
+		{"extendee", 2},
+		{"number", 3},
+		{"label", 4},
+		{"type", 5},
+		{"type_name", 6},
+		{"default_value", 7},
+		{"options", 8},
+		{"oneof_index", 9},
+		{"json_name", 10},
+		{"proto3_optional", 17},
+	}
+	mp, ok, err := FindFieldMap(fields)
+	if err != nil || !ok {
+		t.Fatalf("got ok %v, err %v", ok, err)
+	}
+	for _, f := range fields {
+		if n, ok := mp.Get(f.Name); !ok || n != f.Number {
+			t.Errorf("Get(%q) = %d, %v; expected %d", f.Name, n, ok, f.Number)
+		}
+	}
+	for _, name := range []string{"", "Name", "names", "type_url", "json", "proto3_optional_"} {
+		if n, ok := mp.Get(name); ok {
+			t.Errorf("Get(%q) = %d for an unknown field", name, n)
+		}
+	}
+
+	fields = append(fields, Field{"label", 11})
+	if _, _, err := FindFieldMap(fields); err == nil || !strings.Contains(err.Error(), `"label"`) {
+		t.Errorf("got error %v, expected a duplicate name error", err)
+	}
+}
+
+func TestMapSet(t *testing.T) {
+	keys := []string{"386", "amd64", "arm", "wasm"}
+	mp, ok := findMap(keys, []int{32, 64, 32, 32})
+	if !ok {
+		t.Fatal("could not find map")
+	}
+	before := mp.m.SortedEntries()
+
+	if !mp.Set("arm", 64) {
+		t.Fatal("could not set an existing key")
+	}
+	if v, ok := mp.Get("arm"); !ok || v != 64 {
+		t.Errorf("got %d, %v for arm, expected 64", v, ok)
+	}
+	if v, _ := mp.Get("386"); v != 32 {
+		t.Errorf("got %d for 386, expected 32", v)
+	}
+	if mp.Set("ppc64", 64) {
+		t.Error("set an unknown key")
+	}
+	if _, ok := mp.Get("ppc64"); ok {
+		t.Error("setting an unknown key added it")
+	}
+	if !reflect.DeepEqual(mp.m.SortedEntries(), before) {
+		t.Errorf("Set moved keys: got %v, expected %v", mp.m.SortedEntries(), before)
+	}
+}
+
+func TestMapMarshalBinary(t *testing.T) {
+	keys := []string{"386", "amd64", "arm", "arm64", "wasm"}
+	mp, ok := findMap(keys, []int{32, 64, -32, 1 << 40, 0})
+	if !ok {
+		t.Fatal("could not find map")
+	}
+	data, err := mp.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded Map[int]
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.m.Equal(mp.m) {
+		t.Error("decoded MPHF differs")
+	}
+	for _, key := range keys {
+		v, _ := mp.Get(key)
+		if got, ok := decoded.Get(key); !ok || got != v {
+			t.Errorf("got %d, %v for %q, expected %d", got, ok, key, v)
+		}
+	}
+
+	for _, n := range []int{0, 1, len(data) - 1} {
+		if err := decoded.UnmarshalBinary(data[:n]); err == nil {
+			t.Errorf("expected an error for %d of %d bytes", n, len(data))
+		}
+	}
+	if err := decoded.UnmarshalBinary(append(data, 0)); err == nil {
+		t.Error("expected an error for trailing data")
+	}
+	var narrow Map[int32]
+	if err := narrow.UnmarshalBinary(data); err == nil {
+		t.Error("expected an error for a value that overflows int32")
+	}
+
+	strs, ok := findMap(keys, []string{"x86", "x86-64", "ARM", "", "WebAssembly"})
+	if !ok {
+		t.Fatal("could not find map")
+	}
+	if data, err = strs.MarshalBinary(); err != nil {
+		t.Fatal(err)
+	}
+	var decodedStrs Map[string]
+	if err := decodedStrs.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := decodedStrs.Get("amd64"); !ok || v != "x86-64" {
+		t.Errorf("got %q, %v for amd64", v, ok)
+	}
+
+	floats, ok := findMap(keys[:1], []float64{1.5})
+	if !ok {
+		t.Fatal("could not find map")
+	}
+	if _, err := floats.MarshalBinary(); err == nil {
+		t.Error("expected an error for float values")
+	}
+}
+
+func TestLazyMap(t *testing.T) {
+	keys := []string{"386", "amd64", "arm", "arm64", "wasm"}
+	calls := make(map[string]int)
+	mp, ok := NewLazyMap(keys, func(key string) int {
+		calls[key]++
+		return len(key)
+	})
+	if !ok {
+		t.Fatal("could not find map")
+	}
+	if len(calls) != 0 {
+		t.Errorf("got calls %v before Get", calls)
+	}
+
+	for i := 0; i < 3; i++ {
+		for _, key := range keys[:4] {
+			if v, ok := mp.Get(key); !ok || v != len(key) {
+				t.Errorf("got %d, %v for %q, expected %d", v, ok, key, len(key))
+			}
+		}
+	}
+	for _, key := range keys[:4] {
+		if calls[key] != 1 {
+			t.Errorf("got %d calls for %q, expected 1", calls[key], key)
+		}
+	}
+	if calls["wasm"] != 0 {
+		t.Error("valFn called for a key that was not looked up")
+	}
+	if _, ok := mp.Get("mips"); ok || calls["mips"] != 0 {
+		t.Error("got value for unknown key")
+	}
+}
+
+func TestReadValueRange(t *testing.T) {
+	var i8 int8
+	for x, ok := range map[int64]bool{127: true, -128: true, 128: false, -129: false} {
+		_, err := readValue(binary.AppendVarint(nil, x), &i8)
+		if (err == nil) != ok || ok && int64(i8) != x {
+			t.Errorf("int8 %d: got %d, %v", x, i8, err)
+		}
+	}
+	var u16 uint16
+	for u, ok := range map[uint64]bool{1<<16 - 1: true, 1 << 16: false, 1 << 63: false} {
+		_, err := readValue(binary.AppendUvarint(nil, u), &u16)
+		if (err == nil) != ok || ok && uint64(u16) != u {
+			t.Errorf("uint16 %d: got %d, %v", u, u16, err)
+		}
+	}
+	var u64 uint64
+	if _, err := readValue(binary.AppendUvarint(nil, 1<<63), &u64); err != nil || u64 != 1<<63 {
+		t.Errorf("uint64: got %d, %v", u64, err)
+	}
+}
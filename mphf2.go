@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// mphf2 is a two level MPHF for large sets. A partition hash splits the keys
+// into partitions, and each partition gets its own mphf. A single mphf for
+// tens of thousands of keys often fails, while the smaller partitions
+// succeed.
+type mphf2 struct {
+	part fnv1a
+	// parts holds the mphf of each partition, or nil for an empty partition
+	parts []*mphf
+	// offsets holds the index of the first jump table slot of each partition
+	offsets []int
+}
+
+// FindMPHF2Level splits cases into partitions and finds a MPHF for each
+// partition. The partition hash is reseeded until all partitions succeed.
+// Returns false if no success after maxAttempts partition hashes.
+//
+// Like a single mphf, a partition is hard to build if its size is just below
+// a power of 2. Choose partitions so that len(cases)/partitions is well below
+// a power of 2.
+func FindMPHF2Level(cases []string, partitions int) (*mphf2, bool) {
+	if partitions < 1 {
+		return nil, false
+	}
+	cases = Deduplicate(cases)
+	maxLen := 0
+	for _, str := range cases {
+		if len(str) > maxLen {
+			maxLen = len(str)
+		}
+	}
+
+	// Each partition gets a few attempts before the partitioning is redone
+	opts := Options{Attempts: 4}
+	for i := 0; i < maxAttempts; i++ {
+		m := &mphf2{
+			part:    newFnv1a(opts.seed(), maxLen),
+			parts:   make([]*mphf, partitions),
+			offsets: make([]int, partitions),
+		}
+		split := make([][]string, partitions)
+		for _, str := range cases {
+			p := m.partition(str)
+			split[p] = append(split[p], str)
+		}
+
+		ok := true
+		offset := 0
+		for p, keys := range split {
+			m.offsets[p] = offset
+			if len(keys) == 0 {
+				continue
+			}
+			if m.parts[p], ok = findMPHFOptions(keys, opts); !ok {
+				break
+			}
+			offset += len(m.parts[p].jmpTab)
+		}
+		if ok {
+			return m, true
+		}
+	}
+	return nil, false
+}
+
+// partition returns the partition of key
+func (m *mphf2) partition(key string) int {
+	return int(m.part.hashString(key) % uint32(len(m.parts)))
+}
+
+// Lookup returns the index of key in the concatenated jump tables of the
+// partitions, or false if key is not in the set.
+func (m *mphf2) Lookup(key string) (int, bool) {
+	p := m.partition(key)
+	if m.parts[p] == nil {
+		return -1, false
+	}
+	ix, ok := m.parts[p].Lookup(key)
+	if !ok {
+		return -1, false
+	}
+	return m.offsets[p] + ix, true
+}
+
+// PartitionHash returns the partition in [0, partitions) of key, for sharding
+// keys before building a MPHF per shard. It uses the plain, unseeded FNV-1a
+// hash of all of key, as hash/fnv.New32a, so that all nodes agree.
+// partitions must be in [1, math.MaxUint32]; PartitionHash panics otherwise.
+func PartitionHash(key string, partitions int) int {
+	if partitions <= 0 || uint64(partitions) > math.MaxUint32 {
+		panic(fmt.Sprintf("PartitionHash: got %d partitions, expected 1 to %d", partitions, uint32(math.MaxUint32)))
+	}
+	var f fnv1a
+	sum := uint32(offset32)
+	for i := 0; i < len(key); i++ {
+		sum = f.hashByte(sum, key[i])
+	}
+	return int(sum % uint32(partitions))
+}
+
+// FindMPHFChunks splits the sorted cases into chunks of at most maxChunk keys
+// and finds a MPHF for each chunk. A chunk that fails is split in three
+// parts, which are tried once more. Halves would not do: the halves of a
+// chunk just below a power of 2 fill their jump tables just as much.
+// Returns the MPHFs, and the keys of the parts that failed too.
+func FindMPHFChunks(cases []string, maxChunk int) ([]*mphf, []string) {
+	if maxChunk < 1 {
+		maxChunk = 1
+	}
+	cases = Deduplicate(cases)
+
+	var chunks []*mphf
+	var failed []string
+	for len(cases) > 0 {
+		n := maxChunk
+		if n > len(cases) {
+			n = len(cases)
+		}
+		chunk := cases[:n]
+		cases = cases[n:]
+
+		if m, ok := findMPHF(append([]string(nil), chunk...)); ok {
+			chunks = append(chunks, m)
+			continue
+		}
+		for _, part := range [][]string{chunk[:n/3], chunk[n/3 : 2*n/3], chunk[2*n/3:]} {
+			if len(part) == 0 {
+				continue
+			}
+			if m, ok := findMPHF(append([]string(nil), part...)); ok {
+				chunks = append(chunks, m)
+			} else {
+				failed = append(failed, part...)
+			}
+		}
+	}
+	return chunks, failed
+}
+
+// LookupChunks probes each chunk for key. Returns the index of the chunk and
+// the jump table index of key in it, or false if no chunk has key.
+func LookupChunks(chunks []*mphf, key string) (chunk, index int, ok bool) {
+	for i, m := range chunks {
+		if ix, ok := m.Lookup(key); ok {
+			return i, ix, true
+		}
+	}
+	return -1, -1, false
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"testing"
+)
+
+func TestFindMPHF2Level(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping large set in short mode")
+	}
+	// A load factor near 1 makes the single level search fail
+	var cases []string
+	for i := 0; i < 4095; i++ {
+		cases = append(cases, fmt.Sprintf("key%06d", i*7919))
+	}
+	if _, ok := findMPHF(append([]string(nil), cases...)); ok {
+		t.Fatal("expected the single level MPHF to fail")
+	}
+
+	m, ok := FindMPHF2Level(append([]string(nil), cases...), 24)
+	if !ok {
+		t.Fatal("could not find two level MPHF")
+	}
+	seen := make(map[int]string)
+	for _, key := range cases {
+		ix, ok := m.Lookup(key)
+		if !ok {
+			t.Fatalf("could not look up %q", key)
+		}
+		if other, dup := seen[ix]; dup {
+			t.Fatalf("got index %d for %q and %q", ix, key, other)
+		}
+		seen[ix] = key
+	}
+	for _, key := range []string{"", "key", "key000001", "unknown"} {
+		if ix, ok := m.Lookup(key); ok {
+			t.Errorf("got index %d for unknown key %q", ix, key)
+		}
+	}
+}
+
+func TestPartitionHash(t *testing.T) {
+	const partitions = 8
+	const n = 8000
+	counts := make([]int, partitions)
+	for i := 0; i < n; i++ {
+		key := fmt.Sprintf("node-key-%d", i)
+		p := PartitionHash(key, partitions)
+		if p < 0 || p >= partitions {
+			t.Fatalf("got partition %d for %q", p, key)
+		}
+		if PartitionHash(key, partitions) != p {
+			t.Fatalf("got different partitions for %q", key)
+		}
+		h := fnv.New32a()
+		h.Write([]byte(key))
+		if expected := int(h.Sum32() % partitions); p != expected {
+			t.Fatalf("got partition %d for %q, expected %d", p, key, expected)
+		}
+		counts[p]++
+	}
+	for p, cnt := range counts {
+		if cnt < n/partitions*8/10 || cnt > n/partitions*12/10 {
+			t.Errorf("got %d keys in partition %d, expected about %d", cnt, p, n/partitions)
+		}
+	}
+
+	for _, partitions := range []int{0, -1} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected a panic for %d partitions", partitions)
+				}
+			}()
+			PartitionHash("key", partitions)
+		}()
+	}
+}
+
+func TestFindMPHFChunks(t *testing.T) {
+	// 255 keys fill the jump table of 256 slots, so a single chunk fails
+	var cases []string
+	for i := 0; i < 255; i++ {
+		cases = append(cases, fmt.Sprintf("key%06d", i*7919))
+	}
+
+	for _, maxChunk := range []int{100, 255} {
+		chunks, failed := FindMPHFChunks(append([]string(nil), cases...), maxChunk)
+		if len(failed) > 0 {
+			t.Errorf("got %d failed keys with maxChunk %d", len(failed), maxChunk)
+		}
+		if len(chunks) < 2 {
+			t.Errorf("got %d chunks with maxChunk %d, expected chunking", len(chunks), maxChunk)
+		}
+		for _, key := range cases {
+			if _, _, ok := LookupChunks(chunks, key); !ok {
+				t.Errorf("could not look up %q with maxChunk %d", key, maxChunk)
+			}
+		}
+		if _, _, ok := LookupChunks(chunks, "key"); ok {
+			t.Error("found unknown key")
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import "strconv"
+
+// maxNumericRange is the largest range of numbers that FindMPHFNumeric
+// indexes directly, with a table entry per number in the range
+const maxNumericRange = 1024
+
+// mphfNumeric is a perfect hash function for decimal number keys, such as
+// HTTP status codes. If the numbers are close together, the index of a key
+// is its number minus the smallest number, without hashing. Otherwise it
+// falls back to a mphf.
+type mphfNumeric struct {
+	min  int
+	keys []string // indexed by number - min, "" if no key has the number
+	m    *mphf    // the fallback, nil if the numbers are indexed directly
+}
+
+// FindMPHFNumeric finds a perfect hash function for cases. If all cases are
+// decimal numbers without leading zeros, and their range is at most
+// maxNumericRange, Lookup indexes the numbers directly. Otherwise it is a mphf
+// from findMPHF.
+// Returns false if no MPHF is found.
+func FindMPHFNumeric(cases []string) (*mphfNumeric, bool) {
+	cases = Deduplicate(append([]string(nil), cases...))
+	if nums, ok := parseNumbers(cases); ok && len(nums) > 0 {
+		min, max := nums[0], nums[0]
+		for _, n := range nums {
+			if n < min {
+				min = n
+			}
+			if n > max {
+				max = n
+			}
+		}
+		if max-min < maxNumericRange {
+			m := &mphfNumeric{min: min, keys: make([]string, max-min+1)}
+			for i, n := range nums {
+				m.keys[n-min] = cases[i]
+			}
+			return m, true
+		}
+	}
+
+	m, ok := findMPHF(cases)
+	if !ok {
+		return nil, false
+	}
+	return &mphfNumeric{m: m}, true
+}
+
+// parseNumbers returns the numbers of cases. Returns false if a case is not
+// the canonical decimal form of a non-negative int, as then two cases could
+// have the same number.
+func parseNumbers(cases []string) ([]int, bool) {
+	nums := make([]int, len(cases))
+	for i, str := range cases {
+		n, err := strconv.Atoi(str)
+		if err != nil || n < 0 || strconv.Itoa(n) != str {
+			return nil, false
+		}
+		nums[i] = n
+	}
+	return nums, true
+}
+
+// Direct reports whether Lookup indexes the numbers directly, rather than
+// hashing the keys.
+func (m *mphfNumeric) Direct() bool {
+	return m.m == nil
+}
+
+// Lookup returns the index of s. Returns false if s is not in the set.
+func (m *mphfNumeric) Lookup(s string) (int, bool) {
+	if m.m != nil {
+		return m.m.Lookup(s)
+	}
+
+	// Parse at most as many digits as the largest number has, so that
+	// long inputs cannot overflow
+	if len(s) == 0 || len(s) > len(m.keys[len(m.keys)-1]) {
+		return -1, false
+	}
+	n := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < '0' || c > '9' {
+			return -1, false
+		}
+		n = n*10 + int(c-'0')
+	}
+	ix := n - m.min
+	if ix < 0 || ix >= len(m.keys) || m.keys[ix] != s {
+		return -1, false
+	}
+	return ix, true
+}
@@ -0,0 +1,55 @@
+package main
+
+import "testing"
+
+func TestFindMPHFNumeric(t *testing.T) {
+	// HTTP status codes
+	codes := []string{"100", "101", "200", "201", "202", "204", "206", "301", "302", "304", "307", "308",
+		"400", "401", "403", "404", "405", "409", "410", "412", "413", "415", "418", "429",
+		"500", "501", "502", "503", "504", "200"}
+	m, ok := FindMPHFNumeric(codes)
+	if !ok {
+		t.Fatal("could not find MPHF")
+	}
+	if !m.Direct() {
+		t.Error("expected the numeric fast path for HTTP status codes")
+	}
+
+	seen := make(map[int]string)
+	for _, code := range codes {
+		ix, ok := m.Lookup(code)
+		if !ok {
+			t.Errorf("Lookup(%q) not found", code)
+			continue
+		}
+		if other, exists := seen[ix]; exists && other != code {
+			t.Errorf("%q and %q map to the same index %d", code, other, ix)
+		}
+		seen[ix] = code
+	}
+	for _, s := range []string{"", "0", "199", "0200", "+200", "2OO", "600", "4040", "99999999999999999999999"} {
+		if ix, ok := m.Lookup(s); ok {
+			t.Errorf("Lookup(%q) = %d for an unknown key", s, ix)
+		}
+	}
+
+	// Numbers too far apart, and keys that are not numbers, are hashed
+	for _, cases := range [][]string{
+		{"1", "1000", "1000000"},
+		{"200", "404", "0200"},
+		{"200", "OK"},
+	} {
+		m, ok := FindMPHFNumeric(cases)
+		if !ok {
+			t.Fatal("could not find MPHF")
+		}
+		if m.Direct() {
+			t.Errorf("expected a hashed MPHF for %q", cases)
+		}
+		for _, str := range cases {
+			if _, ok := m.Lookup(str); !ok {
+				t.Errorf("Lookup(%q) not found", str)
+			}
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+// perfectSet is a MPHF without the keys. It stores only the hash parameters
+// and whether each jump table slot is occupied, so it cannot tell a key from a
+// string that hashes to the slot of a key.
+type perfectSet struct {
+	h     mphf     // hash parameters only, without jmpTab, rank or lengths
+	valid []uint64 // bit set of the occupied jump table slots
+}
+
+// FindPerfectSet finds a MPHF for cases and keeps only what Contains needs.
+// Returns false if no MPHF is found.
+func FindPerfectSet(cases []string) (*perfectSet, bool) {
+	m, ok := findMPHF(cases)
+	if !ok {
+		return nil, false
+	}
+	// Keep the hash parameters, but none of the per slot data
+	s := &perfectSet{h: *m, valid: make([]uint64, (len(m.jmpTab)+63)/64)}
+	s.h.jmpTab, s.h.rank, s.h.lengths = nil, nil, nil
+	for ix, e := range m.jmpTab {
+		if e.valid {
+			s.valid[ix/64] |= 1 << (ix % 64)
+		}
+	}
+	return s, true
+}
+
+// Contains returns true for every key of the set. Like MayContain, it also
+// returns true for a string which is not a key but hashes to the slot of a
+// key, so only query keys of the set, or accept false positives.
+func (s *perfectSet) Contains(key string) bool {
+	ix := s.h.hashString(key)
+	return s.valid[ix/64]&(1<<(ix%64)) != 0
+}
@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestFindPerfectSet(t *testing.T) {
+	for _, cases := range testcases {
+		s, ok := FindPerfectSet(cases)
+		if !ok {
+			t.Fatal("could not find perfect set")
+		}
+		if s.h.jmpTab != nil {
+			t.Error("perfect set stores the keys")
+		}
+		if s.h.rank != nil || s.h.lengths != nil {
+			t.Errorf("perfect set stores %d ranks and %d lengths besides valid", len(s.h.rank), len(s.h.lengths))
+		}
+		for _, str := range cases {
+			if !s.Contains(str) {
+				t.Errorf("Contains(%q) = false for a key", str)
+			}
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import "io"
+
+// Regenerate writes the lookup function for keys like Generate, reusing the
+// hash function, shifts and jump table of prev, which is left unchanged. The
+// removed keys are deleted with Remove and the new keys inserted with Add, so
+// the other keys keep their slots and the output differs from that of prev
+// only in the changed table entries. If the first key of the jump table
+// changes, so do the empty slots, which hold a copy of it. If a key cannot be
+// added, Regenerate falls back to a new MPHF for keys, with the reserved slots
+// and canonicalization of prev. Building prev with a GrowthFactor leaves room
+// in the jump table for the keys to add.
+// Returns the MPHF of the output.
+func Regenerate(w io.Writer, funcName string, prev *mphf, keys []string) (*mphf, error) {
+	m := prev.clone()
+	keep := make(map[string]bool)
+	for _, key := range keys {
+		keep[m.canonical(key)] = true
+	}
+	for _, e := range prev.jmpTab {
+		if e.valid && !keep[e.key] {
+			m.remove(e.key)
+		}
+	}
+	for _, key := range Deduplicate(append([]string(nil), keys...)) {
+		if !m.add(key) {
+			var ok bool
+			opts := Options{ReservedSlots: prev.reserved, Canonicalize: prev.canon}
+			if m, ok = findMPHFOptions(append([]string(nil), keys...), opts); !ok {
+				return nil, errNotFound
+			}
+			break
+		}
+	}
+	// Rebuild the lengths and ranks once, rather than on every change
+	m.initKeys()
+
+	if err := m.Generate(w, funcName); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// clone returns a copy of m that does not share its tables
+func (m *mphf) clone() *mphf {
+	c := *m
+	c.bktShift = append([]byte(nil), m.bktShift...)
+	c.packed = append([]uint64(nil), m.packed...)
+	c.jmpTab = append([]jmpEntry(nil), m.jmpTab...)
+	c.lengths = append([]int(nil), m.lengths...)
+	c.rank = append([]int32(nil), m.rank...)
+	c.reserved = append([]int(nil), m.reserved...)
+	return &c
+}
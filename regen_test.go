@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRegenerate(t *testing.T) {
+	var keys []string
+	for i := 0; i < 60; i++ {
+		keys = append(keys, fmt.Sprintf("key%02d", i))
+	}
+	prev, ok := findMPHFOptions(append([]string(nil), keys...), Options{GrowthFactor: 2, SequentialSeeds: true})
+	if !ok {
+		t.Fatal("could not find MPHF")
+	}
+	var before bytes.Buffer
+	if err := prev.Generate(&before, "lookup"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Replace a key other than the first one in the jump table
+	first := prev.SortedEntries()[0].Key
+	changed := append([]string(nil), keys...)
+	for i, key := range changed {
+		if key != first {
+			changed[i] = "key99"
+			break
+		}
+	}
+
+	var after bytes.Buffer
+	m, err := Regenerate(&after, "lookup", prev, changed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGenerated(t, m, after.Bytes(), "lookup", changed)
+
+	beforeLines := strings.Split(before.String(), "\n")
+	afterLines := strings.Split(after.String(), "\n")
+	if len(beforeLines) != len(afterLines) {
+		t.Fatalf("got %d lines, expected %d", len(afterLines), len(beforeLines))
+	}
+	diff := 0
+	for i := range beforeLines {
+		if beforeLines[i] != afterLines[i] {
+			diff++
+		}
+	}
+	// The removed and the added key, and the shifts line. If Add moves the
+	// bucket of the new key, the old and new slots of its keys change too.
+	if diff > 8 {
+		t.Errorf("changing one key changed %d of %d lines, expected at most 8", diff, len(afterLines))
+	}
+	if _, ok := prev.Lookup("key99"); ok {
+		t.Error("Regenerate modified prev")
+	}
+
+	// The dense indices follow the new keys
+	for i, e := range m.SortedEntries() {
+		if ix, ok := m.DenseIndex(e.Key); !ok || ix != i {
+			t.Errorf("DenseIndex(%q) = %d, %v; expected %d", e.Key, ix, ok, i)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import "errors"
+
+// RetryStrategy decides, before each attempt of the search, whether to try
+// again and how many bytes of each key to hash. attempt counts the attempts
+// made with the current window, from 0, and lastErr is the reason the previous
+// attempt failed, or nil before the first attempt. Each attempt tries a new
+// seed.
+type RetryStrategy interface {
+	Next(attempt int, lastErr error) (tryAgain bool, strlen int)
+}
+
+// Reasons that an attempt of the search fails, passed to RetryStrategy.Next
+var (
+	// errSumCollision means that two keys have the same hash sum
+	errSumCollision = errors.New("mphf: keys have the same hash sum")
+
+	// errNoShifts means that no shift values place all buckets in the jump
+	// table
+	errNoShifts = errors.New("mphf: no shift values place all buckets")
+)
+
+// defaultRetry is the RetryStrategy when Options.Retry is nil. It makes
+// perStrlen attempts with each strlen from first to last, so that a stalled
+// search hashes more bytes to spread the hash sums.
+type defaultRetry struct {
+	first, last int
+	perStrlen   int
+}
+
+func (r defaultRetry) Next(attempt int, lastErr error) (bool, int) {
+	strlen := r.first + attempt/r.perStrlen
+	return strlen <= r.last, strlen
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// MarshalBinary encodes the mphf and its keys.
+//
+// The format is, with integers as unsigned varints unless noted:
+//
+//	hashVersion (1 byte)
+//	offset (4 bytes, big endian)
+//	strlen
+//	flags (1 byte): 1 if lens follow, 2 if positions follow, 4 if noXor,
+//	8 if the suffix is hashed, 16 if the length byte is not hashed, 32 if
+//	reserved slots follow
+//	lens (256 values)
+//	number of positions, followed by the positions
+//	bktMask, jmpMask
+//	bktShift (bktMask+1 bytes)
+//	number of reserved slots, followed by the slots
+//	number of keys, followed by slot, length and bytes of each key
+func (m *mphf) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	var tmp [binary.MaxVarintLen64]byte
+	putUvarint := func(x uint64) {
+		buf.Write(tmp[:binary.PutUvarint(tmp[:], x)])
+	}
+
+	buf.WriteByte(hashVersion)
+	binary.BigEndian.PutUint32(tmp[:], m.fnv.offset)
+	buf.Write(tmp[:4])
+	putUvarint(uint64(m.fnv.strlen))
+	var flags byte
+	if m.fnv.lens != nil {
+		flags |= flagLens
+	}
+	if m.fnv.positions != nil {
+		flags |= flagPositions
+	}
+	if m.noXor {
+		flags |= flagNoXor
+	}
+	if m.fnv.suffix {
+		flags |= flagSuffix
+	}
+	if m.fnv.noLength {
+		flags |= flagNoLength
+	}
+	if m.reserved != nil {
+		flags |= flagReserved
+	}
+	buf.WriteByte(flags)
+	if m.fnv.lens != nil {
+		for _, strlen := range m.fnv.lens {
+			putUvarint(uint64(strlen))
+		}
+	}
+	if m.fnv.positions != nil {
+		putUvarint(uint64(len(*m.fnv.positions)))
+		for _, p := range *m.fnv.positions {
+			putUvarint(uint64(p))
+		}
+	}
+
+	putUvarint(uint64(m.bktMask))
+	putUvarint(uint64(m.jmpMask))
+	buf.Write(m.BucketShifts())
+	if m.reserved != nil {
+		putUvarint(uint64(len(m.reserved)))
+		for _, ix := range m.reserved {
+			putUvarint(uint64(ix))
+		}
+	}
+
+	n := 0
+	for _, e := range m.jmpTab {
+		if e.valid {
+			n++
+		}
+	}
+	putUvarint(uint64(n))
+	for ix, e := range m.jmpTab {
+		if e.valid {
+			putUvarint(uint64(ix))
+			putUvarint(uint64(len(e.key)))
+			buf.WriteString(e.key)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// hashVersion identifies the hashing algorithm of fnv1a and mphf. It must be
+// incremented whenever a change to the hashing makes old tables invalid.
+const hashVersion = 1
+
+// Flags for the optional fnv1a fields, and the mphf jump index
+const (
+	flagLens = 1 << iota
+	flagPositions
+	flagNoXor
+	flagSuffix
+	flagNoLength
+	flagReserved
+)
+
+var errTruncated = errors.New("mphf: truncated data")
+
+// UnmarshalBinary bounds the jump table by the number of keys and reserved
+// slots in the data, so that a small input cannot allocate a huge table. The
+// bound allows a GrowthFactor of up to 32, or minUnmarshalSlots for few keys.
+const (
+	maxSlotsPerKey    = 64
+	minUnmarshalSlots = 1024
+)
+
+// UnmarshalBinary decodes data written by MarshalBinary into m. It rejects
+// data from another hashVersion, and verifies that each key hashes to its slot.
+// Data whose jump table has more than maxSlotsPerKey slots per key, such as
+// that of a large mphf after most keys were removed, does not load.
+func (m *mphf) UnmarshalBinary(data []byte) error {
+	r := bytes.NewReader(data)
+	uvarint := func() (int, error) {
+		x, err := binary.ReadUvarint(r)
+		if err != nil {
+			return 0, errTruncated
+		}
+		if x > 1<<31 {
+			return 0, fmt.Errorf("mphf: value %d out of range", x)
+		}
+		return int(x), nil
+	}
+
+	version, err := r.ReadByte()
+	if err != nil {
+		return errTruncated
+	}
+	if version != hashVersion {
+		return fmt.Errorf("mphf: data has hash algorithm version %d, this code supports version %d", version, hashVersion)
+	}
+
+	var d mphf
+	var offset [4]byte
+	if _, err := io.ReadFull(r, offset[:]); err != nil {
+		return errTruncated
+	}
+	d.fnv.offset = binary.BigEndian.Uint32(offset[:])
+	if d.fnv.strlen, err = uvarint(); err != nil {
+		return err
+	}
+	flags, err := r.ReadByte()
+	if err != nil {
+		return errTruncated
+	}
+	if flags&^(flagLens|flagPositions|flagNoXor|flagSuffix|flagNoLength|flagReserved) != 0 {
+		return fmt.Errorf("mphf: invalid flags %#x", flags)
+	}
+	d.noXor = flags&flagNoXor != 0
+	d.fnv.suffix = flags&flagSuffix != 0
+	d.fnv.noLength = flags&flagNoLength != 0
+	if flags&flagLens != 0 {
+		d.fnv.lens = new([256]int)
+		for i := range d.fnv.lens {
+			if d.fnv.lens[i], err = uvarint(); err != nil {
+				return err
+			}
+		}
+	}
+	if flags&flagPositions != 0 {
+		n, err := uvarint()
+		if err != nil {
+			return err
+		}
+		if n > r.Len() {
+			return errTruncated
+		}
+		positions := make([]int, n)
+		for i := range positions {
+			if positions[i], err = uvarint(); err != nil {
+				return err
+			}
+			if i > 0 && positions[i] <= positions[i-1] {
+				return errors.New("mphf: positions must be increasing")
+			}
+		}
+		d.fnv.positions = &positions
+	}
+
+	bktMask, err := uvarint()
+	if err != nil {
+		return err
+	}
+	jmpMask, err := uvarint()
+	if err != nil {
+		return err
+	}
+	if bktMask&(bktMask+1) != 0 || jmpMask&(jmpMask+1) != 0 {
+		return errors.New("mphf: masks must be one less than a power of 2")
+	}
+	if bktMask >= r.Len() {
+		return errTruncated
+	}
+	d.bktMask, d.jmpMask = uint32(bktMask), uint32(jmpMask)
+	d.bktShift = make([]byte, bktMask+1)
+	if _, err := io.ReadFull(r, d.bktShift); err != nil {
+		return errTruncated
+	}
+	if flags&flagReserved != 0 {
+		n, err := uvarint()
+		if err != nil {
+			return err
+		}
+		if n > r.Len() {
+			return errTruncated
+		}
+		d.reserved = make([]int, n)
+		for i := range d.reserved {
+			if d.reserved[i], err = uvarint(); err != nil {
+				return err
+			}
+			if d.reserved[i] > jmpMask || (i > 0 && d.reserved[i] <= d.reserved[i-1]) {
+				return errors.New("mphf: reserved slots must be increasing jump table indices")
+			}
+		}
+	}
+
+	n, err := uvarint()
+	if err != nil {
+		return err
+	}
+	// Each key takes at least a byte for its slot and one for its length
+	if n > r.Len()/2 {
+		return errTruncated
+	}
+	if maxSlots := max(minUnmarshalSlots, maxSlotsPerKey*(n+len(d.reserved))); jmpMask >= maxSlots {
+		return fmt.Errorf("mphf: jump table of %d slots is too large for %d keys", jmpMask+1, n)
+	}
+	d.jmpTab = make([]jmpEntry, jmpMask+1)
+	for i := 0; i < n; i++ {
+		ix, err := uvarint()
+		if err != nil {
+			return err
+		}
+		keyLen, err := uvarint()
+		if err != nil {
+			return err
+		}
+		if keyLen > r.Len() {
+			return errTruncated
+		}
+		key := make([]byte, keyLen)
+		if _, err := io.ReadFull(r, key); err != nil {
+			return errTruncated
+		}
+		if ix >= len(d.jmpTab) || d.jmpTab[ix].valid || int(d.hashString(string(key))) != ix {
+			return fmt.Errorf("mphf: key %q does not hash to slot %d", key, ix)
+		}
+		if d.isReserved(uint32(ix)) {
+			return fmt.Errorf("mphf: key %q is in reserved slot %d", key, ix)
+		}
+		d.jmpTab[ix] = jmpEntry{string(key), true}
+	}
+	if r.Len() > 0 {
+		return errors.New("mphf: trailing data")
+	}
+
+	d.initKeys()
+	*m = d
+	return nil
+}
+
+// NewMPHFFromParams rebuilds the mphf with the hash offset (with the seed
+// hashed in), strlen and bucket shifts of a mphf without PerLengthStrlen,
+// SkipConstantBytes or noXor. The keys are placed in the jump table again.
+// Returns false if the parameters are inconsistent, or if two keys collide.
+func NewMPHFFromParams(offset uint32, strlen int, bktShift []byte, bktMask, jmpMask uint32, keys []string) (*mphf, bool) {
+	if bktMask&(bktMask+1) != 0 || jmpMask&(jmpMask+1) != 0 || len(bktShift) != int(bktMask)+1 || strlen < 0 {
+		return nil, false
+	}
+	for _, shift := range bktShift {
+		if shift >= 32 {
+			return nil, false
+		}
+	}
+
+	var m mphf
+	m.fnv = fnv1a{offset: offset, strlen: strlen}
+	m.bktMask, m.jmpMask = bktMask, jmpMask
+	m.bktShift = append([]byte(nil), bktShift...)
+	m.jmpTab = make([]jmpEntry, jmpMask+1)
+	for _, key := range keys {
+		ix := m.hashString(key)
+		if e := m.jmpTab[ix]; e.valid && e.key != key {
+			return nil, false
+		}
+		m.jmpTab[ix] = jmpEntry{key, true}
+	}
+	m.initKeys()
+	return &m, true
+}
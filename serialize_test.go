@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMarshalBinary(t *testing.T) {
+	for _, opts := range []Options{{}, {PerLengthStrlen: true}, {SkipConstantBytes: true}, {GrowthFactor: 3}} {
+		for _, cases := range testcases {
+			m, ok := findMPHFOptions(cases, opts)
+			if !ok {
+				t.Fatal("could not find MPHF")
+			}
+			data, err := m.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var loaded mphf
+			if err := loaded.UnmarshalBinary(data); err != nil {
+				t.Fatalf("%v for %v", err, cases)
+			}
+			if !m.Equal(&loaded) || !loaded.Equal(m) {
+				t.Errorf("loaded MPHF differs from original for %v", cases)
+			}
+			for _, str := range cases {
+				if ix, ok := loaded.Lookup(str); !ok || ix != int(m.hashString(str)) {
+					t.Errorf("Lookup(%q) = %d, %v after loading", str, ix, ok)
+				}
+			}
+
+			// Truncated data must not load
+			if err := loaded.UnmarshalBinary(data[:len(data)-1]); err == nil {
+				t.Errorf("expected error for truncated data of %v", cases)
+			}
+		}
+	}
+}
+
+func TestEqual(t *testing.T) {
+	a, ok := findMPHF([]string{"386", "amd64", "arm"})
+	if !ok {
+		t.Fatal("could not find MPHF")
+	}
+	b, ok := newMPHF([]string{"386", "amd64", "arm"}, a.fnv, Options{})
+	if !ok {
+		t.Fatal("could not rebuild MPHF")
+	}
+	if !a.Equal(b) {
+		t.Error("expected MPHFs with the same hash and keys to be equal")
+	}
+
+	c, ok := findMPHF([]string{"386", "amd64", "arm64"})
+	if !ok {
+		t.Fatal("could not find MPHF")
+	}
+	if a.Equal(c) {
+		t.Error("expected MPHFs with different keys to differ")
+	}
+	b.jmpTab = append([]jmpEntry(nil), b.jmpTab...)
+	b.jmpTab[b.hashString("arm")] = jmpEntry{}
+	if a.Equal(b) {
+		t.Error("expected MPHFs with different key sets to differ")
+	}
+}
+
+func TestUnmarshalBinaryVersion(t *testing.T) {
+	m, ok := findMPHF([]string{"386", "amd64", "arm"})
+	if !ok {
+		t.Fatal("could not find MPHF")
+	}
+	data, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data[0] != hashVersion {
+		t.Fatalf("got version byte %d, expected %d", data[0], hashVersion)
+	}
+
+	data[0]++
+	var loaded mphf
+	err = loaded.UnmarshalBinary(data)
+	if err == nil {
+		t.Fatal("expected error for another hash algorithm version")
+	}
+	if !strings.Contains(err.Error(), "version") {
+		t.Errorf("got error %q, expected it to mention the version", err)
+	}
+}
+
+func TestUnmarshalBinaryTableSize(t *testing.T) {
+	// A jump table of 2^27 slots without keys
+	data := []byte{hashVersion, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff, 0xff, 0x3f, 0, 0}
+	var loaded mphf
+	if err := loaded.UnmarshalBinary(data); err == nil || !strings.Contains(err.Error(), "too large") {
+		t.Fatalf("got error %v for a jump table of 2^27 slots without keys", err)
+	}
+
+	// A large table with few keys, but less than minUnmarshalSlots
+	m, ok := findMPHFOptions([]string{"386", "amd64", "arm"}, Options{GrowthFactor: 100})
+	if !ok {
+		t.Fatal("could not find MPHF")
+	}
+	data, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := loaded.UnmarshalBinary(data); err != nil {
+		t.Errorf("could not load a table of %d slots for 3 keys: %v", len(m.jmpTab), err)
+	}
+}
+
+func TestNewMPHFFromParams(t *testing.T) {
+	for _, cases := range testcases {
+		m, ok := findMPHF(cases)
+		if !ok {
+			t.Fatal("could not find MPHF")
+		}
+		if m.fnv.lens != nil || m.fnv.positions != nil || m.noXor {
+			continue
+		}
+		keys := m.keys()
+		r, ok := NewMPHFFromParams(m.fnv.offset, m.fnv.strlen, m.BucketShifts(), m.bktMask, m.jmpMask, keys)
+		if !ok {
+			t.Fatalf("could not rebuild MPHF of %d keys", len(keys))
+		}
+		if !m.Equal(r) {
+			t.Errorf("rebuilt MPHF of %d keys differs", len(keys))
+		}
+
+		if _, ok := NewMPHFFromParams(m.fnv.offset, m.fnv.strlen, m.BucketShifts()[1:], m.bktMask, m.jmpMask, keys); ok {
+			t.Error("rebuilt MPHF with too few shifts")
+		}
+		// A string that is not a key, but hashes to the slot of a key
+		for i := 0; ; i++ {
+			other := fmt.Sprint(strings.Repeat("o", i%8), i)
+			if ix := m.hashString(other); m.jmpTab[ix].valid && m.jmpTab[ix].key != other {
+				if _, ok := NewMPHFFromParams(m.fnv.offset, m.fnv.strlen, m.BucketShifts(), m.bktMask, m.jmpMask, append(keys, other)); ok {
+					t.Errorf("rebuilt MPHF with %q colliding with %q", other, m.jmpTab[ix].key)
+				}
+				break
+			}
+		}
+	}
+}
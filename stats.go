@@ -0,0 +1,192 @@
+package main
+
+import (
+	"math"
+	"slices"
+	"time"
+)
+
+// Stats describes a MPHF found by FindMPHFStats.
+type Stats struct {
+	// MaxBucket is the number of keys in the largest bucket. Buckets of more
+	// than about 8 keys rarely find a shift value.
+	MaxBucket int
+
+	// ChiSquared is the chi-squared statistic of the bucket sizes against a
+	// uniform spread of the keys. Lower is better.
+	ChiSquared float64
+
+	// Elapsed is the duration of the search, and Attempts the number of
+	// hash functions tried. These are set also if the search fails.
+	Elapsed  time.Duration
+	Attempts int
+
+	// TableSize is the number of jump table slots, of which WastedSlots are
+	// empty. Buckets is the number of buckets.
+	TableSize   int
+	WastedSlots int
+	Buckets     int
+
+	// RecommendLinear is set if the MPHF has fewer than linearThreshold
+	// distinct keys, where comparing the key to each case in turn is faster
+	// than hashing.
+	RecommendLinear bool
+}
+
+// linearThreshold is the number of keys from which a MPHF lookup beats a
+// chain of string comparisons. Below it, the hash costs more than the few
+// comparisons it saves, and most comparisons fail on the length alone.
+const linearThreshold = 8
+
+// FindMPHFStats is findMPHFOptions, and also returns statistics about the
+// MPHF.
+func FindMPHFStats(cases []string, opts Options) (*mphf, Stats, bool) {
+	var stats Stats
+	start := time.Now()
+	m, info, attempts, ok := findMPHFCount(cases, opts)
+	stats.Elapsed = time.Since(start)
+	stats.Attempts = attempts
+	if !ok {
+		return nil, stats, false
+	}
+
+	stats.TableSize = info.tableSize
+	stats.WastedSlots = info.wasted
+	stats.Buckets = info.buckets
+	stats.RecommendLinear = info.valid < linearThreshold
+
+	keys := m.keys()
+	stats.ChiSquared, stats.MaxBucket = qualityMetrics(keys, m.fnv, m.bktMask)
+	return m, stats, true
+}
+
+// AttemptHistogram runs the seed search of findHash on cases runs times, and
+// counts the runs by the number of seeds tried. hist[n] is the number of runs
+// that found a hash function with the n-th seed, and hist[maxAttempts+1] the
+// number of runs that found none in maxAttempts seeds.
+func AttemptHistogram(cases []string, runs int) []int {
+	var opts Options
+	cases = Deduplicate(append([]string(nil), cases...))
+	f := hashTemplate(cases, MinInputLen(cases), opts)
+	sums := make([]uint32, len(cases))
+
+	hist := make([]int, maxAttempts+2)
+	for run := 0; run < runs; run++ {
+		n := 1
+		for n <= maxAttempts && !hashSums(cases, f.withSeed(opts.seed()), sums) {
+			n++
+		}
+		hist[n]++
+	}
+	return hist
+}
+
+// keys returns the valid keys in jump table order
+func (m *mphf) keys() []string {
+	var keys []string
+	for _, e := range m.jmpTab {
+		if e.valid {
+			keys = append(keys, e.key)
+		}
+	}
+	return keys
+}
+
+// bucketSizes returns the number of cases in each bucket when the cases are
+// hashed with fnv into bktMask+1 buckets.
+func bucketSizes(cases []string, fnv fnv1a, bktMask uint32) []int {
+	sizes := make([]int, bktMask+1)
+	for _, str := range cases {
+		sizes[fnv.hashString(str)&bktMask]++
+	}
+	return sizes
+}
+
+// maxBucketSize returns the number of cases in the largest bucket when the
+// cases are hashed with fnv into bktMask+1 buckets.
+func maxBucketSize(cases []string, fnv fnv1a, bktMask uint32) int {
+	return slices.Max(bucketSizes(cases, fnv, bktMask))
+}
+
+// qualityMetrics measures how evenly fnv spreads the cases over bktMask+1
+// buckets. It returns the chi-squared statistic of the bucket sizes, where
+// each bucket is expected to get the same number of cases, and the size of
+// the largest bucket from maxBucketSize.
+func qualityMetrics(cases []string, fnv fnv1a, bktMask uint32) (chiSquared float64, maxBucket int) {
+	if len(cases) == 0 {
+		return 0, 0
+	}
+	sizes := bucketSizes(cases, fnv, bktMask)
+	expected := float64(len(cases)) / float64(len(sizes))
+	for _, size := range sizes {
+		d := float64(size) - expected
+		chiSquared += d * d / expected
+	}
+	return chiSquared, maxBucketSize(cases, fnv, bktMask)
+}
+
+// CollisionResistance returns roughly how many distinct unknown strings an
+// attacker needs, on average, to hit every occupied jump table slot. Unknown
+// strings land in uniformly random slots, so by the coupon collector's
+// problem it takes size/k strings to hit one of k remaining occupied slots.
+func (m *mphf) CollisionResistance() int {
+	size := float64(len(m.jmpTab))
+	n := 0.0
+	for _, e := range m.jmpTab {
+		if e.valid {
+			n++
+		}
+	}
+
+	expected := 0.0
+	for k := n; k > 0; k-- {
+		expected += size / k
+	}
+	return int(math.Round(expected))
+}
+
+// AvgBytesHashed returns the average number of content bytes that
+// hashString hashes per key
+func (m *mphf) AvgBytesHashed() float64 {
+	keys := m.keys()
+	if len(keys) == 0 {
+		return 0
+	}
+	total := 0
+	for _, key := range keys {
+		total += m.fnv.inputLen(key)
+	}
+	return float64(total) / float64(len(keys))
+}
+
+// Lookup cost model of EstimatedLookupNs, in nanoseconds. The fixed cost
+// covers the length byte, the bucket shift and the jump table access, and with
+// the cost per hashed byte it matches BenchmarkJumpTables/mphf, which hashes
+// about 2.7 bytes per key in 16ns on amd64. The compare cost is a guess for
+// short keys.
+const (
+	lookupFixedNs   = 13
+	lookupByteNs    = 1
+	lookupCompareNs = 2
+)
+
+// EstimatedLookupNs returns a rough estimate of the duration of a Lookup of a
+// key, for comparing MPHFs without a benchmark. It is a heuristic from
+// AvgBytesHashed only: it ignores the CPU, caches, and the cost of Canonicalize.
+func (m *mphf) EstimatedLookupNs() float64 {
+	return lookupFixedNs + lookupByteNs*m.AvgBytesHashed() + lookupCompareNs
+}
+
+// ShiftHistogram counts the non-empty buckets by their shift value. Most
+// buckets at shift 0 indicate an easy set, many high shifts a hard one.
+func (m *mphf) ShiftHistogram() [32]int {
+	used := make(map[uint32]bool)
+	for _, key := range m.keys() {
+		used[m.fnv.hashString(key)&m.bktMask] = true
+	}
+	var hist [32]int
+	for bkt := range used {
+		hist[m.shiftAt(bkt)]++
+	}
+	return hist
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestMaxBucketSize(t *testing.T) {
+	// Skew the set: 6 keys in bucket 0 and one key in each other bucket
+	fnv := newFnv1a(1, 8)
+	const bktMask = 7
+	var cases []string
+	var sizes [bktMask + 1]int
+	for i := 0; len(cases) < 6+bktMask; i++ {
+		str := fmt.Sprintf("key%d", i)
+		bkt := fnv.hashString(str) & bktMask
+		if (bkt == 0 && sizes[bkt] < 6) || (bkt != 0 && sizes[bkt] < 1) {
+			sizes[bkt]++
+			cases = append(cases, str)
+		}
+	}
+
+	counts := make(map[uint32]int)
+	manual := 0
+	for _, str := range cases {
+		bkt := fnv.hashString(str) & bktMask
+		counts[bkt]++
+		if counts[bkt] > manual {
+			manual = counts[bkt]
+		}
+	}
+	if got := maxBucketSize(cases, fnv, bktMask); got != manual || got != 6 {
+		t.Errorf("got max bucket size %d, expected %d", got, manual)
+	}
+}
+
+func TestFindMPHFStats(t *testing.T) {
+	for _, cases := range testcases {
+		m, stats, ok := FindMPHFStats(cases, Options{})
+		if !ok {
+			t.Fatal("could not find MPHF")
+		}
+		if expected := maxBucketSize(cases, m.fnv, m.bktMask); stats.MaxBucket != expected {
+			t.Errorf("got MaxBucket %d, expected %d", stats.MaxBucket, expected)
+		}
+		if stats.MaxBucket < 1 || stats.MaxBucket > len(cases) {
+			t.Errorf("got MaxBucket %d for %d keys", stats.MaxBucket, len(cases))
+		}
+	}
+}
+
+func TestQualityMetrics(t *testing.T) {
+	for _, cases := range testcases {
+		m, stats, ok := FindMPHFStats(cases, Options{})
+		if !ok {
+			t.Fatal("could not find MPHF")
+		}
+		chiSquared, maxBucket := qualityMetrics(cases, m.fnv, m.bktMask)
+		if math.IsNaN(chiSquared) || math.IsInf(chiSquared, 0) || chiSquared < 0 {
+			t.Errorf("got chi-squared %v for %q", chiSquared, cases)
+		}
+		if maxBucket != maxBucketSize(cases, m.fnv, m.bktMask) {
+			t.Errorf("got max bucket %d, expected %d", maxBucket, maxBucketSize(cases, m.fnv, m.bktMask))
+		}
+		if stats.ChiSquared != chiSquared {
+			t.Errorf("got Stats.ChiSquared %v, expected %v", stats.ChiSquared, chiSquared)
+		}
+	}
+
+	// All keys in one bucket is the worst spread
+	fnv := newFnv1a(1, 8)
+	var skewed []string
+	for i := 0; len(skewed) < 8; i++ {
+		if str := fmt.Sprintf("key%d", i); fnv.hashString(str)&3 == 0 {
+			skewed = append(skewed, str)
+		}
+	}
+	if chiSquared, maxBucket := qualityMetrics(skewed, fnv, 3); chiSquared != 24 || maxBucket != 8 {
+		t.Errorf("got %v, %d for a single bucket, expected 24, 8", chiSquared, maxBucket)
+	}
+}
+
+func TestFindMPHFStatsCost(t *testing.T) {
+	for _, cases := range testcases {
+		_, stats, ok := FindMPHFStats(cases, Options{})
+		if !ok {
+			t.Fatal("could not find MPHF")
+		}
+		if stats.Attempts < 1 {
+			t.Errorf("got %d attempts, expected at least 1", stats.Attempts)
+		}
+		if stats.Elapsed <= 0 {
+			t.Errorf("got elapsed time %v, expected more than 0", stats.Elapsed)
+		}
+	}
+
+	// A failed search reports its cost too
+	cases := []string{"ab", "ac"}
+	_, stats, ok := FindMPHFStats(cases, Options{FixedStrLen: 1})
+	if ok || stats.Attempts != 0 {
+		t.Errorf("got ok %v and %d attempts for an impossible strlen", ok, stats.Attempts)
+	}
+	var many []string
+	for i := 0; i < 15; i++ {
+		many = append(many, fmt.Sprintf("key%02d", i))
+	}
+	_, stats, ok = FindMPHFStats(many, Options{Rand: rand.New(rand.NewSource(10)), Attempts: 1, FixedStrLen: 5})
+	if ok || stats.Attempts != 1 {
+		t.Errorf("got ok %v and %d attempts, expected a single failed attempt", ok, stats.Attempts)
+	}
+}
+
+func TestCollisionResistance(t *testing.T) {
+	cases := []string{"386", "amd64", "arm", "arm64", "ppc64", "wasm"}
+	m, ok := findMPHF(append([]string(nil), cases...))
+	if !ok {
+		t.Fatal("could not find MPHF")
+	}
+	large, ok := findMPHFOptions(append([]string(nil), cases...), Options{GrowthFactor: 4})
+	if !ok {
+		t.Fatal("could not find MPHF with GrowthFactor 4")
+	}
+
+	r := m.CollisionResistance()
+	if r <= 0 {
+		t.Errorf("got collision resistance %d, expected positive", r)
+	}
+	// 8 slots and 6 keys: 8 * (1/6 + 1/5 + ... + 1/1) = 19.6
+	if len(m.jmpTab) == 8 && r != 20 {
+		t.Errorf("got collision resistance %d, expected 20", r)
+	}
+	if large.CollisionResistance() <= r {
+		t.Errorf("got collision resistance %d for %d slots, and %d for %d slots",
+			large.CollisionResistance(), len(large.jmpTab), r, len(m.jmpTab))
+	}
+}
+
+func TestBuildInfo(t *testing.T) {
+	for _, cases := range testcases {
+		m, stats, ok := FindMPHFStats(cases, Options{})
+		if !ok {
+			t.Fatal("could not find MPHF")
+		}
+		valid := len(m.keys())
+		if stats.TableSize != len(m.jmpTab) || stats.Buckets != len(m.bktShift) {
+			t.Errorf("got table size %d and %d buckets, expected %d and %d",
+				stats.TableSize, stats.Buckets, len(m.jmpTab), len(m.bktShift))
+		}
+		if stats.WastedSlots != stats.TableSize-valid {
+			t.Errorf("got %d wasted slots, expected %d - %d", stats.WastedSlots, stats.TableSize, valid)
+		}
+	}
+
+	cases := []string{"386", "amd64", "arm"}
+	fnv, _, sums, ok := findSumsWith(cases, hashTemplate(cases, MinInputLen(cases), Options{}), Options{})
+	if !ok {
+		t.Fatal("could not find hash")
+	}
+	_, info, ok := newMPHFInfo(cases, sums, fnv, Options{GrowthFactor: 4})
+	if !ok {
+		t.Fatal("could not find MPHF")
+	}
+	if info.tableSize != 16 || info.valid != 3 || info.wasted != 13 {
+		t.Errorf("got %+v, expected 16 slots with 3 keys", info)
+	}
+}
+
+func TestRecommendLinear(t *testing.T) {
+	_, stats, ok := FindMPHFStats([]string{"386", "amd64", "arm"}, Options{})
+	if !ok {
+		t.Fatal("could not find MPHF")
+	}
+	if !stats.RecommendLinear {
+		t.Error("expected RecommendLinear for 3 keys")
+	}
+
+	// Duplicates are not counted
+	var dups []string
+	for i := 0; i < 4; i++ {
+		dups = append(dups, "386", "amd64", "arm")
+	}
+	_, stats, ok = FindMPHFStats(dups, Options{})
+	if !ok {
+		t.Fatal("could not find MPHF")
+	}
+	if !stats.RecommendLinear {
+		t.Errorf("expected RecommendLinear for %d cases with 3 distinct keys", len(dups))
+	}
+
+	var cases []string
+	for i := 0; i < 100; i++ {
+		cases = append(cases, fmt.Sprintf("key%03d", i))
+	}
+	_, stats, ok = FindMPHFStats(cases, Options{})
+	if !ok {
+		t.Fatal("could not find MPHF")
+	}
+	if stats.RecommendLinear {
+		t.Error("expected no RecommendLinear for 100 keys")
+	}
+}
+
+func TestAttemptHistogram(t *testing.T) {
+	const runs = 50
+	for _, cases := range testcases {
+		hist := AttemptHistogram(cases, runs)
+		if len(hist) != maxAttempts+2 {
+			t.Fatalf("got %d counts, expected %d", len(hist), maxAttempts+2)
+		}
+		total := 0
+		for _, n := range hist {
+			total += n
+		}
+		if total != runs {
+			t.Errorf("histogram sums to %d, expected %d", total, runs)
+		}
+		if hist[0] != 0 {
+			t.Errorf("got %d runs with no seeds tried", hist[0])
+		}
+	}
+}
+
+func TestEstimatedLookupNs(t *testing.T) {
+	var cases []string
+	for i := 0; i < 50; i++ {
+		cases = append(cases, fmt.Sprintf("%02d_common_suffix", i))
+	}
+	short, ok := findMPHFOptions(append([]string(nil), cases...), Options{FixedStrLen: 2})
+	if !ok {
+		t.Fatal("could not find MPHF")
+	}
+	long, ok := findMPHFOptions(append([]string(nil), cases...), Options{FixedStrLen: 16})
+	if !ok {
+		t.Fatal("could not find MPHF")
+	}
+	if s, l := short.AvgBytesHashed(), long.AvgBytesHashed(); s != 2 || l != 16 {
+		t.Errorf("got %v and %v bytes hashed, expected 2 and 16", s, l)
+	}
+	if s, l := short.EstimatedLookupNs(), long.EstimatedLookupNs(); s >= l {
+		t.Errorf("got estimate %vns for strlen 2, %vns for strlen 16", s, l)
+	}
+}
+
+func TestShiftHistogram(t *testing.T) {
+	for _, cases := range testcases {
+		m, ok := findMPHF(cases)
+		if !ok {
+			t.Fatal("could not find MPHF")
+		}
+		nonEmpty := make(map[uint32]bool)
+		for _, str := range cases {
+			nonEmpty[m.fnv.hashString(str)&m.bktMask] = true
+		}
+		hist := m.ShiftHistogram()
+		total := 0
+		for _, n := range hist {
+			total += n
+		}
+		if total != len(nonEmpty) {
+			t.Errorf("histogram sums to %d, expected %d non-empty buckets", total, len(nonEmpty))
+		}
+	}
+}
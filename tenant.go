@@ -0,0 +1,69 @@
+package main
+
+import (
+	"cmp"
+	"strings"
+)
+
+// TenantKey is a key in the key space of a tenant. The same key of two
+// tenants are different keys.
+type TenantKey struct {
+	Tenant uint32
+	Key    string
+}
+
+// hashTenant hashes the 4 bytes of tenant, least significant byte first, and
+// then key as hashString does.
+func (f fnv1a) hashTenant(tenant uint32, key string) uint32 {
+	g := f
+	for w := uint(0); w < 32; w += 8 {
+		g.offset = f.hashByte(g.offset, byte(tenant>>w))
+	}
+	return g.hashString(key)
+}
+
+// mphfTenant is a (near) minimal perfect hash function for keys of several
+// tenants, on the hash of the tenant followed by the key.
+type mphfTenant struct {
+	mphfKeyed[TenantKey]
+}
+
+// FindMPHFWithTenant tries seeds until it finds a near minimal perfect hash
+// function for keys.
+// Returns true if found, false if no success after maxAttempts iterations.
+func FindMPHFWithTenant(keys []TenantKey) (*mphfTenant, bool) {
+	keys = distinctKeys(keys, func(a, b TenantKey) int {
+		if a.Tenant != b.Tenant {
+			return cmp.Compare(a.Tenant, b.Tenant)
+		}
+		return strings.Compare(a.Key, b.Key)
+	})
+
+	// The tenant tells keys of different tenants apart, so strlen only needs
+	// to tell apart the keys of each tenant
+	strlen := 0
+	for i := 0; i < len(keys); {
+		var group []string
+		tenant := keys[i].Tenant
+		for ; i < len(keys) && keys[i].Tenant == tenant; i++ {
+			group = append(group, keys[i].Key)
+		}
+		if n := MinInputLen(group); n > strlen {
+			strlen = n
+		}
+	}
+
+	m, ok := findMPHFKeyed(keys, strlen, func(f fnv1a, key TenantKey) uint32 {
+		return f.hashTenant(key.Tenant, key.Key)
+	})
+	if !ok {
+		return nil, false
+	}
+	return &mphfTenant{*m}, true
+}
+
+// Lookup returns the jump table index of key of tenant. Returns false if the
+// key is not in the set.
+func (m *mphfTenant) Lookup(tenant uint32, key string) (int, bool) {
+	return m.mphfKeyed.Lookup(TenantKey{tenant, key})
+}
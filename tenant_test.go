@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+func TestFindMPHFWithTenant(t *testing.T) {
+	keys := []TenantKey{
+		{1, "users"}, {1, "orders"}, {1, "items"},
+		{2, "users"}, {2, "orders"},
+		{3, "users"},
+	}
+	m, ok := FindMPHFWithTenant(keys)
+	if !ok {
+		t.Fatal("could not find MPHF")
+	}
+
+	seen := make(map[int]TenantKey)
+	for _, key := range keys {
+		ix, ok := m.Lookup(key.Tenant, key.Key)
+		if !ok {
+			t.Fatalf("could not look up %+v", key)
+		}
+		if other, dup := seen[ix]; dup {
+			t.Errorf("got index %d for %+v and %+v", ix, key, other)
+		}
+		seen[ix] = key
+	}
+
+	for _, key := range []TenantKey{{2, "items"}, {3, "orders"}, {4, "users"}, {1, "user"}} {
+		if ix, ok := m.Lookup(key.Tenant, key.Key); ok {
+			t.Errorf("got index %d for unknown %+v", ix, key)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+// Code generated by go generate; DO NOT EDIT.
+
+package lookup
+
+// Lookup returns the jump table index of s, or -1 if s is not a key.
+func Lookup(s string) int {
+	sum := uint32(0xfb69b604)
+	sum ^= uint32(byte(len(s)))
+	sum *= 16777619
+	for i := 0; i < len(s) && i < 8; i++ {
+		sum ^= uint32(s[i])
+		sum *= 16777619
+	}
+	sum = ((sum >> LookupShifts[sum&0x7]) ^ sum) & 0x1f
+	if LookupKeys[sum] != s {
+		return -1
+	}
+	return int(sum)
+}
+
+var LookupShifts = [...]byte{2, 2, 4, 1, 2, 1, 1, 5}
+
+// Empty slots hold the key of another slot, so they never match
+var LookupKeys = [...]string{
+	"bool",
+	"int64",
+	"string",
+	"int32",
+	"bool",
+	"uintptr",
+	"error",
+	"complex128",
+	"bool",
+	"unsafe.Pointer",
+	"float64",
+	"bool",
+	"uint64",
+	"bool",
+	"int8",
+	"byte",
+	"bool",
+	"int",
+	"bool",
+	"bool",
+	"bool",
+	"complex64",
+	"uint32",
+	"uint16",
+	"uint",
+	"bool",
+	"int16",
+	"uint8",
+	"rune",
+	"bool",
+	"bool",
+	"float32",
+}
@@ -0,0 +1,24 @@
+package main
+
+import "cmp"
+
+// hashUint64 hashes the 8 bytes of x, least significant byte first. All keys
+// have the same length, so there is no length byte and strlen is not used.
+func (f fnv1a) hashUint64(x uint64) uint32 {
+	sum := f.offset
+	for w := uint(0); w < 64; w += 8 {
+		sum = f.hashByte(sum, byte(x>>w))
+	}
+	return sum
+}
+
+// mphfUint is a (near) minimal perfect hash function for uint64 keys, on the
+// hash of the integer value.
+type mphfUint = mphfKeyed[uint64]
+
+// FindMPHFUint64 tries seeds until it finds a near minimal perfect hash
+// function for keys.
+// Returns true if found, false if no success after maxAttempts iterations.
+func FindMPHFUint64(keys []uint64) (*mphfUint, bool) {
+	return findMPHFKeyed(distinctKeys(keys, cmp.Compare[uint64]), 0, fnv1a.hashUint64)
+}
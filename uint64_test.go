@@ -0,0 +1,34 @@
+package main
+
+import "testing"
+
+func TestFindMPHFUint64(t *testing.T) {
+	// Sparse opcodes
+	keys := []uint64{0x01, 0x0f, 0x10, 0xff, 0x1234, 0xdead, 0xbeef, 0xcafebabe, 1 << 40, 1<<63 | 7, 42, 42}
+	m, ok := FindMPHFUint64(keys)
+	if !ok {
+		t.Fatal("could not find MPHF")
+	}
+
+	seen := make(map[int]uint64)
+	for _, key := range keys {
+		ix, ok := m.Lookup(key)
+		if !ok {
+			t.Errorf("Lookup(%#x) not found", key)
+			continue
+		}
+		if other, exists := seen[ix]; exists && other != key {
+			t.Errorf("%#x and %#x map to the same index %d", key, other, ix)
+		}
+		seen[ix] = key
+	}
+	if len(seen) != len(keys)-1 {
+		t.Errorf("got %d distinct indices, expected %d", len(seen), len(keys)-1)
+	}
+
+	for _, key := range []uint64{0, 2, 0x11, 1 << 41} {
+		if ix, ok := m.Lookup(key); ok {
+			t.Errorf("Lookup(%#x) = %d, expected not found", key, ix)
+		}
+	}
+}
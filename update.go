@@ -0,0 +1,106 @@
+package main
+
+// Add inserts key into the mphf without rebuilding it. If the jump table slot
+// of key is taken, Add looks for another shift value for the bucket of key,
+// which moves the other keys in that bucket. Keys are never moved to reserved
+// slots, see Options.ReservedSlots.
+// Returns false if key cannot be added, and the mphf must be rebuilt instead.
+func (m *mphf) Add(key string) bool {
+	if !m.add(key) {
+		return false
+	}
+	m.initKeys()
+	return true
+}
+
+// add is Add without updating the fields derived from the keys, see initKeys
+func (m *mphf) add(key string) bool {
+	key = m.canonical(key)
+	sum := m.fnv.hashString(key)
+	bkt := sum & m.bktMask
+
+	// Collect the sums of the other keys in the bucket
+	var sums []uint32
+	for _, e := range m.jmpTab {
+		if !e.valid {
+			continue
+		}
+		if e.key == key {
+			return true
+		}
+		s := m.fnv.hashString(e.key)
+		if s == sum {
+			// The hash cannot tell key apart from e.key
+			return false
+		}
+		if s&m.bktMask == bkt {
+			sums = append(sums, s)
+		}
+	}
+	sums = append(sums, sum)
+
+	// Try the current shift first, to keep the other keys in place
+	shifts := []byte{m.shiftAt(bkt)}
+	for shift := byte(0); shift < 32; shift++ {
+		if shift != m.shiftAt(bkt) {
+			shifts = append(shifts, shift)
+		}
+	}
+
+	old := make(map[uint32]bool)
+	for _, s := range sums[:len(sums)-1] {
+		old[m.jmpIx(s, m.shiftAt(bkt))] = true
+	}
+	for _, shift := range shifts {
+		newJump := make(map[uint32]bool)
+		shiftOk := true
+		for _, s := range sums {
+			ix := m.jmpIx(s, shift)
+			if (m.jmpTab[ix].valid && !old[ix]) || newJump[ix] || m.isReserved(ix) {
+				shiftOk = false
+				break
+			}
+			newJump[ix] = true
+		}
+		if !shiftOk {
+			continue
+		}
+
+		// Move the bucket to its new slots
+		var entries []jmpEntry
+		for ix := range old {
+			entries = append(entries, m.jmpTab[ix])
+			m.jmpTab[ix] = jmpEntry{}
+		}
+		m.setShift(bkt, shift)
+		entries = append(entries, jmpEntry{key, true})
+		for _, e := range entries {
+			m.jmpTab[m.hashString(e.key)] = e
+		}
+		return true
+	}
+	return false
+}
+
+// Remove deletes key from the mphf without rebuilding it. The jump table keeps
+// its size, and the slot of key is free for a later Add.
+// Returns false if key is not in the mphf.
+func (m *mphf) Remove(key string) bool {
+	if !m.remove(key) {
+		return false
+	}
+	m.initKeys()
+	return true
+}
+
+// remove is Remove without updating the fields derived from the keys, see
+// initKeys
+func (m *mphf) remove(key string) bool {
+	key = m.canonical(key)
+	ix := m.hashString(key)
+	if e := m.jmpTab[ix]; !e.valid || e.key != key {
+		return false
+	}
+	m.jmpTab[ix] = jmpEntry{}
+	return true
+}
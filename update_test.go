@@ -0,0 +1,78 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestGrowthFactor(t *testing.T) {
+	cases := []string{"386", "amd64", "arm", "arm64", "ppc64", "wasm"}
+	added := []string{"mips", "mips64", "riscv64", "s390x"}
+
+	m, ok := findMPHFOptions(append([]string(nil), cases...), Options{Rand: rand.New(rand.NewSource(1))})
+	if !ok {
+		t.Fatal("could not find MPHF")
+	}
+	m2, ok := findMPHFOptions(append([]string(nil), cases...), Options{Rand: rand.New(rand.NewSource(1)), GrowthFactor: 2})
+	if !ok {
+		t.Fatal("could not find MPHF with GrowthFactor 2")
+	}
+	if len(m2.jmpTab) <= len(m.jmpTab) {
+		t.Errorf("got jump table size %d, expected more than %d", len(m2.jmpTab), len(m.jmpTab))
+	}
+	if len(m2.jmpTab) <= 2*len(cases) {
+		t.Errorf("got jump table size %d, expected more than %d", len(m2.jmpTab), 2*len(cases))
+	}
+
+	for _, key := range added {
+		if !m2.Add(key) {
+			t.Errorf("could not add %q", key)
+		}
+	}
+	for _, key := range append(cases, added...) {
+		if e := m2.jmpTab[m2.hashString(key)]; !e.valid || e.key != key {
+			t.Errorf("got entry %+v for %q", e, key)
+		}
+	}
+}
+
+func TestRemove(t *testing.T) {
+	cases := []string{"386", "amd64", "arm", "arm64", "ppc64", "wasm"}
+	m, ok := findMPHF(append([]string(nil), cases...))
+	if !ok {
+		t.Fatal("could not find MPHF")
+	}
+	size := len(m.jmpTab)
+
+	if !m.Remove("arm") {
+		t.Fatal("could not remove arm")
+	}
+	if m.Remove("arm") {
+		t.Error("removed arm twice")
+	}
+	if m.Remove("mips") {
+		t.Error("removed mips, which is not a key")
+	}
+	if ix, ok := m.Lookup("arm"); ok {
+		t.Errorf("got index %d for removed key", ix)
+	}
+	for _, key := range cases {
+		if key == "arm" {
+			continue
+		}
+		if _, ok := m.Lookup(key); !ok {
+			t.Errorf("could not look up %q after Remove", key)
+		}
+	}
+	if len(m.jmpTab) != size {
+		t.Errorf("got jump table size %d after Remove, expected %d", len(m.jmpTab), size)
+	}
+
+	// The slot is free for Add
+	if !m.Add("arm") {
+		t.Fatal("could not add arm after Remove")
+	}
+	if _, ok := m.Lookup("arm"); !ok {
+		t.Error("could not look up arm after Add")
+	}
+}
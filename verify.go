@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Patterns of the Go source written by Generate
+var (
+	genFuncRE    = regexp.MustCompile(`func (\w+)\(s string\) int \{`)
+	genOffsetRE  = regexp.MustCompile(`\tsum := uint32\((0x[0-9a-f]+)\)`)
+	genStrlenRE  = regexp.MustCompile(`i < len\(s\) && i < (\d+);`)
+	genSuffixRE  = regexp.MustCompile(`\ti := len\(s\) - (\d+)\n`)
+	genLensRE    = regexp.MustCompile(`case (\d+):\n\t\tn = (\d+)\n`)
+	genPosRE     = regexp.MustCompile(`range \[\.\.\.\]int\{([\d, ]*)\}`)
+	genCaseRE    = regexp.MustCompile(`if s == ("(?:[^"\\]|\\.)*") \{\n\t+return (\d+)\n`)
+	genKeyLineRE = regexp.MustCompile(`^\t("(?:[^"\\]|\\.)*"),$`)
+)
+
+// VerifyGenerated parses the Go source written by Generate from genFile, and
+// checks that its tables encode a MPHF whose keys are exactly keys: each key
+// hashes to its own jump table slot, and no other key is in the table. This
+// catches generated files that are stale after the keys changed.
+// Returns an error describing the first mismatch, or if genFile is not the
+// output of Generate.
+func VerifyGenerated(genFile io.Reader, keys []string) error {
+	data, err := io.ReadAll(genFile)
+	if err != nil {
+		return err
+	}
+	src := string(data)
+	match := genFuncRE.FindStringSubmatch(src)
+	if match == nil {
+		return fmt.Errorf("verify: no lookup function found")
+	}
+	name := match[1]
+
+	var entries map[string]int
+	if strings.Contains(src, "var "+name+"Keys = ") {
+		m, err := parseGenerated(src, name)
+		if err != nil {
+			return err
+		}
+		entries = m.Indices()
+	} else {
+		// Generate writes a switch on len(s) if the keys have distinct lengths
+		entries = make(map[string]int)
+		for _, match := range genCaseRE.FindAllStringSubmatch(src, -1) {
+			key, err := strconv.Unquote(match[1])
+			if err != nil {
+				return fmt.Errorf("verify: invalid key %s: %v", match[1], err)
+			}
+			entries[key], _ = strconv.Atoi(match[2])
+		}
+	}
+
+	want := make(map[string]bool)
+	for _, key := range keys {
+		want[key] = true
+		if _, ok := entries[key]; !ok {
+			return fmt.Errorf("verify: key %q is missing from the generated tables", key)
+		}
+	}
+	var extra []string
+	for key := range entries {
+		if !want[key] {
+			extra = append(extra, key)
+		}
+	}
+	if len(extra) > 0 {
+		sort.Strings(extra)
+		return fmt.Errorf("verify: generated tables have keys %q that are not in the key list", extra)
+	}
+	return nil
+}
+
+// parseGenerated rebuilds the mphf of the lookup function name and its tables
+// in src. Only the keys that hash to their own slot are valid.
+func parseGenerated(src, name string) (*mphf, error) {
+	var m mphf
+	match := genOffsetRE.FindStringSubmatch(src)
+	if match == nil {
+		return nil, fmt.Errorf("verify: %s does not hash inline", name)
+	}
+	offset, _ := strconv.ParseUint(match[1], 0, 32)
+	m.fnv.offset = uint32(offset)
+	m.fnv.noLength = !strings.Contains(src, "\tsum ^= uint32(byte(len(s)))\n")
+
+	if match := genPosRE.FindStringSubmatch(src); match != nil {
+		positions := []int{}
+		for _, p := range strings.Split(match[1], ",") {
+			n, err := strconv.Atoi(strings.TrimSpace(p))
+			if err != nil {
+				return nil, fmt.Errorf("verify: invalid position %q", p)
+			}
+			positions = append(positions, n)
+		}
+		m.fnv.positions = &positions
+	} else if matches := genLensRE.FindAllStringSubmatch(src, -1); matches != nil {
+		m.fnv.lens = new([256]int)
+		for _, match := range matches {
+			lb, _ := strconv.Atoi(match[1])
+			strlen, _ := strconv.Atoi(match[2])
+			if lb > 255 {
+				return nil, fmt.Errorf("verify: invalid length byte %d", lb)
+			}
+			m.fnv.lens[lb] = strlen
+		}
+	} else if match := genSuffixRE.FindStringSubmatch(src); match != nil {
+		m.fnv.suffix = true
+		m.fnv.strlen, _ = strconv.Atoi(match[1])
+	} else if match := genStrlenRE.FindStringSubmatch(src); match != nil {
+		m.fnv.strlen, _ = strconv.Atoi(match[1])
+	} else {
+		return nil, fmt.Errorf("verify: no hash loop found in %s", name)
+	}
+
+	jmpixRE := regexp.MustCompile(regexp.QuoteMeta(name) + `Shifts\[sum&(0x[0-9a-f]+)\]\)( \^ sum\))? & (0x[0-9a-f]+)`)
+	match = jmpixRE.FindStringSubmatch(src)
+	if match == nil {
+		return nil, fmt.Errorf("verify: no jump table index found in %s", name)
+	}
+	bktMask, _ := strconv.ParseUint(match[1], 0, 32)
+	jmpMask, _ := strconv.ParseUint(match[3], 0, 32)
+	m.bktMask, m.jmpMask = uint32(bktMask), uint32(jmpMask)
+	m.noXor = match[2] == ""
+
+	shiftsRE := regexp.MustCompile(`var ` + regexp.QuoteMeta(name) + `Shifts = \[\.\.\.\]byte\{([\d, ]*)\}`)
+	match = shiftsRE.FindStringSubmatch(src)
+	if match == nil {
+		return nil, fmt.Errorf("verify: no %sShifts table found", name)
+	}
+	for _, s := range strings.Split(match[1], ",") {
+		shift, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil || shift >= 32 {
+			return nil, fmt.Errorf("verify: invalid shift %q", s)
+		}
+		m.bktShift = append(m.bktShift, byte(shift))
+	}
+	if len(m.bktShift) != int(m.bktMask)+1 {
+		return nil, fmt.Errorf("verify: got %d shifts, expected %d", len(m.bktShift), m.bktMask+1)
+	}
+
+	// The keys table starts after its declaration and ends at the first line
+	// that is not a key
+	start := strings.Index(src, "var "+name+"Keys = [...]string{\n")
+	lines := strings.Split(src[start:], "\n")[1:]
+	for _, line := range lines {
+		match := genKeyLineRE.FindStringSubmatch(line)
+		if match == nil {
+			break
+		}
+		key, err := strconv.Unquote(match[1])
+		if err != nil {
+			return nil, fmt.Errorf("verify: invalid key %s: %v", match[1], err)
+		}
+		m.jmpTab = append(m.jmpTab, jmpEntry{key: key})
+	}
+	if len(m.jmpTab) != int(m.jmpMask)+1 {
+		return nil, fmt.Errorf("verify: got %d keys, expected %d jump table slots", len(m.jmpTab), m.jmpMask+1)
+	}
+	for ix := range m.jmpTab {
+		m.jmpTab[ix].valid = int(m.hashString(m.jmpTab[ix].key)) == ix
+	}
+	m.initKeys()
+	return &m, nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestVerifyGenerated(t *testing.T) {
+	tests := []struct {
+		name  string
+		cases []string
+		opts  Options
+	}{
+		{"lengths", []string{"a", "bb", "ccc", "dddddd"}, Options{}},
+		{"jumptable", []string{"386", "amd64", "arm", "arm64", "ppc64", "wasm"}, Options{}},
+		{"perlength", []string{"a", "bb", "TrimPrefix", "TrimSuffix", "Trim", "Tree"}, Options{PerLengthStrlen: true}},
+		{"positions", []string{"key_a_1", "key_b_1", "key_a_2", "key_b_2"}, Options{SkipConstantBytes: true}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.opts.Rand = rand.New(rand.NewSource(1))
+			m, ok := findMPHFOptions(append([]string(nil), tc.cases...), tc.opts)
+			if !ok {
+				t.Fatal("could not find MPHF")
+			}
+			var buf bytes.Buffer
+			if err := m.Generate(&buf, "lookup"); err != nil {
+				t.Fatal(err)
+			}
+			if err := VerifyGenerated(bytes.NewReader(buf.Bytes()), tc.cases); err != nil {
+				t.Errorf("matching file: %v\n%s", err, buf.Bytes())
+			}
+
+			// A key was added since the file was generated
+			added := append([]string{"added"}, tc.cases...)
+			if err := VerifyGenerated(bytes.NewReader(buf.Bytes()), added); err == nil || !strings.Contains(err.Error(), `"added"`) {
+				t.Errorf("got %v for a missing key", err)
+			}
+
+			// A key was removed since the file was generated
+			removed := tc.cases[1:]
+			if err := VerifyGenerated(bytes.NewReader(buf.Bytes()), removed); err == nil || !strings.Contains(err.Error(), tc.cases[0]) {
+				t.Errorf("got %v for a removed key", err)
+			}
+		})
+	}
+
+	if err := VerifyGenerated(strings.NewReader("package main\n"), nil); err == nil {
+		t.Error("expected an error for a file without a lookup function")
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+// WeightedKey is a key with the weight of its placement, such as its lookup
+// frequency.
+type WeightedKey struct {
+	Key    string
+	Weight int
+}
+
+// FindMPHFWeighted tries seeds until it finds a near minimal perfect hash
+// function for the keys, preferring low jump table indices for heavy keys.
+// This is best effort: the shift value of a bucket is the valid shift with the
+// least weighted sum of indices, but the hash itself is not chosen by weight.
+// The weights of a repeated key are added up.
+// Returns true if found, false if no success after maxAttempts iterations.
+func FindMPHFWeighted(keys []WeightedKey) (*mphf, bool) {
+	var opts Options
+
+	// Prepare input data
+	weight := make(map[string]int)
+	var cases []string
+	for _, k := range keys {
+		if _, dup := weight[k.Key]; !dup {
+			cases = append(cases, k.Key)
+		}
+		weight[k.Key] += k.Weight
+	}
+	cases = Deduplicate(cases)
+	tmpl := hashTemplate(cases, MinInputLen(cases), opts)
+
+	for i := 0; i < opts.attempts(); i++ {
+		fnv, seed, sums, ok := findSumsWith(cases, tmpl, opts)
+		if !ok {
+			continue
+		}
+		weights := make([]int, len(cases))
+		for i, str := range cases {
+			weights[i] = weight[str]
+		}
+
+		var m mphf
+		m.fnv = fnv
+		m.seed = seed
+		m.initTables(len(cases), opts)
+		if !m.initBuckets(sums, nil, weights, false) {
+			continue
+		}
+		m.jmpTab = make([]jmpEntry, m.jmpMask+1)
+		for i, str := range cases {
+			m.jmpTab[m.jmpIx(sums[i], m.bktShift[sums[i]&m.bktMask])] = jmpEntry{str, true}
+		}
+		m.initKeys()
+		return &m, true
+	}
+	return nil, false
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestFindMPHFWeighted(t *testing.T) {
+	var keys []WeightedKey
+	for i := 0; i < 20; i++ {
+		keys = append(keys, WeightedKey{fmt.Sprintf("key%02d", i), 1})
+	}
+	const hot = "key07"
+	keys = append(keys, WeightedKey{hot, 1000})
+
+	// Placement is best effort, so compare the average over several builds
+	const builds = 20
+	hotSum, otherSum, others := 0, 0, 0
+	for b := 0; b < builds; b++ {
+		m, ok := FindMPHFWeighted(keys)
+		if !ok {
+			t.Fatal("could not find MPHF")
+		}
+		for _, k := range keys[:20] {
+			ix, ok := m.Lookup(k.Key)
+			if !ok {
+				t.Fatalf("key %q not found", k.Key)
+			}
+			if k.Key == hot {
+				hotSum += ix
+			} else {
+				otherSum += ix
+				others++
+			}
+		}
+	}
+	hotAvg := float64(hotSum) / builds
+	otherAvg := float64(otherSum) / float64(others)
+	if hotAvg >= otherAvg {
+		t.Errorf("hot key has average index %.1f, other keys %.1f", hotAvg, otherAvg)
+	}
+}